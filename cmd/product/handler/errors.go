@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"product/cmd/product/service"
+	"product/infrastructure/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errorStatuses = map[service.ErrorKind]int{
+	service.KindValidation:    http.StatusBadRequest,
+	service.KindNotFound:      http.StatusNotFound,
+	service.KindConflict:      http.StatusConflict,
+	service.KindUnavailable:   http.StatusServiceUnavailable,
+	service.KindUnprocessable: http.StatusUnprocessableEntity,
+	service.KindForbidden:     http.StatusForbidden,
+	service.KindInternal:      http.StatusInternalServerError,
+}
+
+// writeError renders every handler error with the same envelope, errors outside the taxonomy become a 500
+func writeError(c *gin.Context, err error) {
+	var domainErr *service.Error
+	if !errors.As(err, &domainErr) {
+		log.Logger.Errorf("unclassified error %v", err)
+		domainErr = service.NewError(service.KindInternal, "internal_error", "internal server error", err)
+	}
+
+	status, ok := errorStatuses[domainErr.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	body := gin.H{
+		"error_code":    domainErr.Code,
+		"error_message": domainErr.Message,
+		"request_id":    c.GetString("request_id"),
+	}
+
+	if len(domainErr.Fields) > 0 {
+		body["fields"] = domainErr.Fields
+	}
+
+	c.AbortWithStatusJSON(status, body)
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"product/cmd/product/service"
+	"product/infrastructure/log"
+	"product/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// rows written between flushes, keeps the response moving without a syscall per row
+const exportFlushEvery = 1000
+
+// currency comes last so files read by position keep their layout
+var productExportColumns = []string{"id", "name", "description", "price", "stock", "category_id", "category", "version", "currency"}
+
+// productExporter writes one export format, begin and end frame the rows
+type productExporter interface {
+	begin() error
+	write(product *models.Product) error
+	end() error
+}
+
+// ExportProducts streams every product matching the search filters, the response is written as the rows are read.
+// the route runs without the request deadline and stops when the client goes away.
+// a failure after the first byte can only cut the body short, it is logged and the json array is left unclosed
+func (h *ProductHandler) ExportProducts(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+
+	var contentType string
+	var exporter productExporter
+
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+		exporter = &csvExporter{writer: csv.NewWriter(c.Writer)}
+	case "ndjson":
+		contentType = "application/x-ndjson"
+		exporter = &jsonExporter{writer: c.Writer, lines: true}
+	case "json":
+		contentType = "application/json; charset=utf-8"
+		exporter = &jsonExporter{writer: c.Writer}
+	default:
+		writeError(c, service.NewValidationError("unsupported_export_format", "format must be csv, ndjson or json"))
+
+		return
+	}
+
+	param := &models.SearchProductParameter{}
+	if !searchFilters(c, param) {
+		return
+	}
+
+	started := false
+	rows := 0
+
+	// headers wait for the first row so a failing query can still answer with a proper error
+	start := func() error {
+		started = true
+
+		filename := fmt.Sprintf("products-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Status(http.StatusOK)
+
+		return exporter.begin()
+	}
+
+	err := h.ProductUsecase.ExportProducts(c.Request.Context(), param, func(product *models.Product) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		if err := exporter.write(product); err != nil {
+			return err
+		}
+
+		if rows++; rows%exportFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+
+		return nil
+	})
+	if err != nil {
+		entry := log.Logger.WithFields(logrus.Fields{
+			"format": format,
+			"rows":   rows,
+		})
+
+		if started {
+			// the 200 and part of the body are already out, the client is left with a short file
+			entry.Errorf("export truncated after %d rows, h.ProductUsecase.ExportProducts got error %v", rows, err)
+
+			return
+		}
+
+		entry.Errorf("h.ProductUsecase.ExportProducts got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	// nothing matched, the empty file still gets its header row or brackets
+	if !started {
+		if err := start(); err != nil {
+			log.Logger.Errorf("exporter.begin got error %v", err)
+
+			return
+		}
+	}
+
+	if err := exporter.end(); err != nil {
+		log.Logger.Errorf("exporter.end got error %v", err)
+	}
+}
+
+type csvExporter struct {
+	writer *csv.Writer
+}
+
+func (e *csvExporter) begin() error {
+	return e.writer.Write(productExportColumns)
+}
+
+func (e *csvExporter) write(product *models.Product) error {
+	return e.writer.Write([]string{
+		strconv.FormatInt(product.ID, 10),
+		product.Name,
+		product.Description,
+		product.Price.String(),
+		strconv.Itoa(product.Stock),
+		strconv.Itoa(product.CategoryID),
+		product.Category,
+		strconv.FormatInt(product.Version, 10),
+		product.Currency,
+	})
+}
+
+func (e *csvExporter) end() error {
+	e.writer.Flush()
+
+	return e.writer.Error()
+}
+
+// jsonExporter writes a json array, or one object per line when lines is set
+type jsonExporter struct {
+	writer io.Writer
+	lines  bool
+	count  int
+}
+
+func (e *jsonExporter) begin() error {
+	if e.lines {
+		return nil
+	}
+
+	_, err := io.WriteString(e.writer, "[")
+
+	return err
+}
+
+func (e *jsonExporter) write(product *models.Product) error {
+	productJSON, err := json.Marshal(product)
+	if err != nil {
+		return err
+	}
+
+	separator := ","
+	if e.lines {
+		separator = ""
+		productJSON = append(productJSON, '\n')
+	} else if e.count == 0 {
+		separator = ""
+	}
+
+	e.count++
+
+	_, err = io.WriteString(e.writer, separator+string(productJSON))
+
+	return err
+}
+
+func (e *jsonExporter) end() error {
+	if e.lines {
+		return nil
+	}
+
+	_, err := io.WriteString(e.writer, "]")
+
+	return err
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"product/cmd/product/repository"
+	"product/cmd/product/service"
+	"product/cmd/product/usecase"
+	"product/config"
+	"product/infrastructure/log"
+	"product/models"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	log.Logger = logrus.New()
+	log.Logger.SetOutput(io.Discard)
+	gin.SetMode(gin.TestMode)
+
+	os.Exit(m.Run())
+}
+
+// newTestHandler wires the handler through the usecase and service to a repository on a miniredis and a mocked postgres
+func newTestHandler(t *testing.T) (*ProductHandler, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:         logger.Discard,
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redisClient := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	productService := service.NewProductService(*repository.NewProductRepository(redisClient, db), &config.Config{})
+	productUsecase := usecase.NewProductUsecase(*productService)
+
+	return NewProductHandler(*productUsecase), mock
+}
+
+func TestExportProductsWritesListPrice(t *testing.T) {
+	h, mock := newTestHandler(t)
+
+	// the sale join never matches on an export, so price is the list price
+	mock.ExpectQuery(`(?s)product\.price, product\.list_price, .*AND false`).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "description", "price", "list_price", "currency", "stock", "category_id", "version", "deleted_at", "category"}).
+			AddRow(1, "keyboard", "", "10.00", "10.00", "USD", 5, 1, 3, nil, "peripherals"))
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/products/export?format=ndjson&min_price=5", nil)
+
+	h.ExportProducts(c)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", recorder.Code, recorder.Body.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d rows, want 1: %s", len(lines), recorder.Body.String())
+	}
+
+	var product models.Product
+	if err := json.Unmarshal([]byte(lines[0]), &product); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := models.ParseDecimal("10.00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if product.Price != want || product.ListPrice != want {
+		t.Errorf("got price %s list_price %s, want both %s", product.Price, product.ListPrice, want)
+	}
+
+	if product.ID != 1 || product.Category != "peripherals" || product.Version != 3 {
+		t.Errorf("got row %+v", product)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
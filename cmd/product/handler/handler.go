@@ -1,12 +1,15 @@
 package handler
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"product/cmd/product/service"
 	"product/cmd/product/usecase"
 	"product/infrastructure/log"
 	"product/models"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -32,26 +35,34 @@ func (h *ProductHandler) GetProductCategoryByID(c *gin.Context) {
 			"productCategoryID": productCategoryID,
 		}).Errorf("strconv.ParseInt got error %v", err)
 
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error_message": "Invalid Product Category ID",
-		})
+		writeError(c, service.NewValidationError("invalid_product_category_id", "Invalid Product Category ID"))
+
+		return
+	}
 
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
 		return
 	}
 
-	product, err := h.ProductUsecase.GetProductCategoryByID(c.Request.Context(), productCategoryID)
+	var product *models.ProductCategory
+	if withDeleted {
+		product, err = h.ProductUsecase.GetProductCategoryByIDWithDeleted(c.Request.Context(), productCategoryID)
+	} else {
+		product, err = h.ProductUsecase.GetProductCategoryByID(c.Request.Context(), productCategoryID)
+	}
+
 	if err != nil {
 		log.Logger.WithFields(logrus.Fields{
 			"productCategoryID": productCategoryID,
 		}).Errorf("h.ProductUsecase.GetProductCategoryByID %v", err)
 
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error_message": err.Error(),
-		})
+		writeError(c, err)
 
 		return
 	}
 
+	setETag(c, product.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"message":          "Success",
 		"product_category": product,
@@ -61,12 +72,10 @@ func (h *ProductHandler) GetProductCategoryByID(c *gin.Context) {
 func (h *ProductHandler) ProductCategoryManagement(c *gin.Context) {
 	var param models.ProductCategoryManagementParameter
 
-	if err := c.ShouldBindJSON(&param); err != nil {
+	if err := decodeBody(c, &param); err != nil {
 		log.Logger.Error(err.Error())
 
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error_message": "Invalid Input",
-		})
+		writeError(c, service.NewValidationError("invalid_input", "Invalid Input"))
 
 		return
 	}
@@ -74,15 +83,19 @@ func (h *ProductHandler) ProductCategoryManagement(c *gin.Context) {
 	if param.Action == "" {
 		log.Logger.Error("Missing required action parameter")
 
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error_message": "Missing required action parameter",
-		})
+		writeError(c, service.NewValidationError("missing_action", "Missing required action parameter"))
 
 		return
 	}
 
 	switch param.Action {
 	case "add":
+		if err := validateStruct(&param.ProductCategory); err != nil {
+			writeBindError(c, err)
+
+			return
+		}
+
 		ProductCategoryID, err := h.ProductUsecase.CreateNewProductCategory(c.Request.Context(), &param.ProductCategory)
 
 		if err != nil {
@@ -90,9 +103,7 @@ func (h *ProductHandler) ProductCategoryManagement(c *gin.Context) {
 				"param": param,
 			}).Errorf("h.ProductUsecase.CreateNewProductCategory got error %v", err)
 
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error_message": err,
-			})
+			writeError(c, err)
 
 			return
 		}
@@ -108,13 +119,45 @@ func (h *ProductHandler) ProductCategoryManagement(c *gin.Context) {
 				"param": param,
 			}).Error("Invalid request - product is empty")
 
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error_message": "Invalid request",
-			})
+			writeError(c, service.NewValidationError("invalid_request", "Invalid request"))
+
+			return
+		}
+
+		if err := validateStruct(&param.ProductCategory); err != nil {
+			writeBindError(c, err)
 
 			return
 		}
 
+		version, err := ifMatchVersion(c, param.Version)
+		if err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"param": param,
+			}).Errorf("ifMatchVersion got error %v", err)
+
+			writeError(c, service.NewValidationError("invalid_if_match", "Invalid If-Match header"))
+
+			return
+		}
+
+		param.Version = version
+
+		// callers of the action api predate parent_id, leaving it out keeps the category where it is
+		var fields map[string]json.RawMessage
+		if err := decodeBody(c, &fields); err == nil {
+			if _, ok := fields["parent_id"]; !ok {
+				current, err := h.ProductUsecase.GetProductCategoryByID(c.Request.Context(), param.ID)
+				if err != nil {
+					writeError(c, err)
+
+					return
+				}
+
+				param.ParentID = current.ParentID
+			}
+		}
+
 		ProductCategory, err := h.ProductUsecase.EditProductCategory(c.Request.Context(), &param.ProductCategory)
 
 		if err != nil {
@@ -122,13 +165,12 @@ func (h *ProductHandler) ProductCategoryManagement(c *gin.Context) {
 				"param": param,
 			}).Errorf("h.ProductUsecase.EditProductCategory got error %v", err)
 
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error_message": err,
-			})
+			writeError(c, err)
 
 			return
 		}
 
+		setETag(c, ProductCategory.Version)
 		c.JSON(http.StatusOK, gin.H{
 			"message":          "Successfully edit product category.",
 			"product_category": ProductCategory,
@@ -141,23 +183,19 @@ func (h *ProductHandler) ProductCategoryManagement(c *gin.Context) {
 				"param": param,
 			}).Error("Invalid request - product is empty")
 
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error_message": "Invalid request",
-			})
+			writeError(c, service.NewValidationError("invalid_request", "Invalid request"))
 
 			return
 		}
 
-		err := h.ProductUsecase.DeleteProductCategory(c.Request.Context(), param.ID)
+		err := h.ProductUsecase.DeleteProductCategory(c.Request.Context(), param.ID, &param.DeleteProductCategoryParameter)
 
 		if err != nil {
 			log.Logger.WithFields(logrus.Fields{
 				"param": param,
 			}).Errorf("h.ProductUsecase.DeleteProductCategory got error %v", err)
 
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error_message": err,
-			})
+			writeError(c, err)
 
 			return
 		}
@@ -166,13 +204,41 @@ func (h *ProductHandler) ProductCategoryManagement(c *gin.Context) {
 			"message": fmt.Sprintf("Successfully delete product category ID %d.", param.ID),
 		})
 
+		return
+	case "restore":
+		if param.ID == 0 {
+			log.Logger.WithFields(logrus.Fields{
+				"param": param,
+			}).Error("Invalid request - product category id is empty")
+
+			writeError(c, service.NewValidationError("invalid_request", "Invalid request"))
+
+			return
+		}
+
+		productCategory, err := h.ProductUsecase.RestoreProductCategory(c.Request.Context(), param.ID)
+
+		if err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"param": param,
+			}).Errorf("h.ProductUsecase.RestoreProductCategory got error %v", err)
+
+			writeError(c, err)
+
+			return
+		}
+
+		setETag(c, productCategory.Version)
+		c.JSON(http.StatusOK, gin.H{
+			"message":          fmt.Sprintf("Successfully restore product category ID %d.", param.ID),
+			"product_category": productCategory,
+		})
+
 		return
 
 	default:
 		log.Logger.Error("Invalid Action")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error_message": "Invalid Action",
-		})
+		writeError(c, service.NewValidationError("invalid_action", "Invalid Action"))
 
 		return
 	}
@@ -188,26 +254,50 @@ func (h *ProductHandler) GetProductByID(c *gin.Context) {
 			"productID": productID,
 		}).Errorf("strconv.ParseInt got error %v", err)
 
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error_message": "Invalid Product ID",
-		})
+		writeError(c, service.NewValidationError("invalid_product_id", "Invalid Product ID"))
+
+		return
+	}
+
+	withDeleted, ok := includeDeleted(c)
+	if !ok {
+		return
+	}
 
+	currency, ok := queryCurrency(c)
+	if !ok {
 		return
 	}
 
-	product, err := h.ProductUsecase.GetProductByID(c.Request.Context(), productID)
+	asOf, ok := queryTime(c, "as_of")
+	if !ok {
+		return
+	}
+
+	if asOf != nil {
+		h.getProductAsOf(c, productID, currency, *asOf, withDeleted)
+
+		return
+	}
+
+	var product *models.Product
+	if withDeleted {
+		product, err = h.ProductUsecase.GetProductByIDWithDeleted(c.Request.Context(), productID, currency)
+	} else {
+		product, err = h.ProductUsecase.GetProductByID(c.Request.Context(), productID, currency)
+	}
+
 	if err != nil {
 		log.Logger.WithFields(logrus.Fields{
 			"productID": productID,
 		}).Errorf("h.ProductUsecase.GetProductByID %v", err)
 
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error_message": err.Error(),
-		})
+		writeError(c, err)
 
 		return
 	}
 
+	setETag(c, product.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Success",
 		"product": product,
@@ -217,12 +307,10 @@ func (h *ProductHandler) GetProductByID(c *gin.Context) {
 func (h *ProductHandler) ProductManagement(c *gin.Context) {
 	var param models.ProductManagementParameter
 
-	if err := c.ShouldBindJSON(&param); err != nil {
+	if err := decodeBody(c, &param); err != nil {
 		log.Logger.Error(err.Error())
 
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error_message": "Invalid Input",
-		})
+		writeError(c, service.NewValidationError("invalid_input", "Invalid Input"))
 
 		return
 	}
@@ -230,15 +318,19 @@ func (h *ProductHandler) ProductManagement(c *gin.Context) {
 	if param.Action == "" {
 		log.Logger.Error("Missing required action parameter")
 
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error_message": "Missing required action parameter",
-		})
+		writeError(c, service.NewValidationError("missing_action", "Missing required action parameter"))
 
 		return
 	}
 
 	switch param.Action {
 	case "add":
+		if err := validateStruct(&param.Product); err != nil {
+			writeBindError(c, err)
+
+			return
+		}
+
 		productID, err := h.ProductUsecase.CreateNewProduct(c.Request.Context(), &param.Product)
 
 		if err != nil {
@@ -246,9 +338,7 @@ func (h *ProductHandler) ProductManagement(c *gin.Context) {
 				"param": param,
 			}).Errorf("h.ProductUsecase.CreateNewProduct got error %v", err)
 
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error_message": err,
-			})
+			writeError(c, err)
 
 			return
 		}
@@ -264,27 +354,52 @@ func (h *ProductHandler) ProductManagement(c *gin.Context) {
 				"param": param,
 			}).Error("Invalid request - product id is empty")
 
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error_message": "Invalid request",
-			})
+			writeError(c, service.NewValidationError("invalid_request", "Invalid request"))
+
+			return
+		}
+
+		var patch models.ProductPatch
+
+		if err := decodeBody(c, &patch); err != nil {
+			log.Logger.Error(err.Error())
+
+			writeError(c, service.NewValidationError("invalid_input", "Invalid Input"))
+
+			return
+		}
+
+		if err := validateStruct(&patch); err != nil {
+			writeBindError(c, err)
+
+			return
+		}
+
+		version, err := ifMatchVersion(c, patch.Version)
+		if err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"param": param,
+			}).Errorf("ifMatchVersion got error %v", err)
+
+			writeError(c, service.NewValidationError("invalid_if_match", "Invalid If-Match header"))
 
 			return
 		}
 
-		product, err := h.ProductUsecase.EditProduct(c.Request.Context(), &param.Product)
+		patch.Version = version
+		product, err := h.ProductUsecase.PatchProduct(c.Request.Context(), param.ID, &patch)
 
 		if err != nil {
 			log.Logger.WithFields(logrus.Fields{
 				"param": param,
-			}).Errorf("h.ProductUsecase.EditProduct got error %v", err)
+			}).Errorf("h.ProductUsecase.PatchProduct got error %v", err)
 
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error_message": err,
-			})
+			writeError(c, err)
 
 			return
 		}
 
+		setETag(c, product.Version)
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Successfully edit product.",
 			"product": product,
@@ -297,9 +412,7 @@ func (h *ProductHandler) ProductManagement(c *gin.Context) {
 				"param": param,
 			}).Error("Invalid request - product id is empty")
 
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error_message": "Invalid request",
-			})
+			writeError(c, service.NewValidationError("invalid_request", "Invalid request"))
 
 			return
 		}
@@ -311,9 +424,7 @@ func (h *ProductHandler) ProductManagement(c *gin.Context) {
 				"param": param,
 			}).Errorf("h.ProductUsecase.DeleteProduct got error %v", err)
 
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error_message": err,
-			})
+			writeError(c, err)
 
 			return
 		}
@@ -322,74 +433,281 @@ func (h *ProductHandler) ProductManagement(c *gin.Context) {
 			"message": fmt.Sprintf("Successfully delete product ID %d.", param.ID),
 		})
 
+		return
+	case "restore":
+		if param.ID == 0 {
+			log.Logger.WithFields(logrus.Fields{
+				"param": param,
+			}).Error("Invalid request - product id is empty")
+
+			writeError(c, service.NewValidationError("invalid_request", "Invalid request"))
+
+			return
+		}
+
+		product, err := h.ProductUsecase.RestoreProduct(c.Request.Context(), param.ID)
+
+		if err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"param": param,
+			}).Errorf("h.ProductUsecase.RestoreProduct got error %v", err)
+
+			writeError(c, err)
+
+			return
+		}
+
+		setETag(c, product.Version)
+		c.JSON(http.StatusOK, gin.H{
+			"message": fmt.Sprintf("Successfully restore product ID %d.", param.ID),
+			"product": product,
+		})
+
 		return
 
 	default:
 		log.Logger.Error("Invalid Action")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error_message": "Invalid Action",
-		})
+		writeError(c, service.NewValidationError("invalid_action", "Invalid Action"))
 
 		return
 	}
 }
 
 func (h *ProductHandler) SearchProduct(c *gin.Context) {
-	name := c.Query("name")
-	category := c.Query("category")
-
-	minPrice, _ := strconv.ParseFloat(c.Query("min_price"), 64)
-	maxPrice, _ := strconv.ParseFloat(c.Query("max_price"), 64)
-
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 
-	orderBy := c.Query("order_by")
-	sort := c.Query("sort")
+	if page < 1 {
+		page = 1
+	}
+
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	includeTotal, err := strconv.ParseBool(c.DefaultQuery("include_total", "true"))
+	if err != nil {
+		writeError(c, service.NewValidationError("invalid_include_total", "include_total must be true or false"))
+
+		return
+	}
 
 	param := &models.SearchProductParameter{
-		Name:     name,
-		Category: category,
-		MinPrice: minPrice,
-		MaxPrice: maxPrice,
-		Page:     page,
-		PageSize: pageSize,
-		OrderBy:  orderBy,
-		Sort:     sort,
+		Page:         page,
+		PageSize:     pageSize,
+		OrderBy:      c.Query("order_by"),
+		Sort:         c.Query("sort"),
+		Cursor:       c.Query("cursor"),
+		IncludeTotal: includeTotal,
+		Facets:       queryList(c, "facets"),
+	}
+
+	if !searchFilters(c, param) {
+		return
+	}
+
+	var ok bool
+
+	if param.PriceBuckets, ok = queryDecimalList(c, "price_buckets"); !ok {
+		return
 	}
 
-	products, totalCount, err := h.ProductUsecase.SearchProduct(c.Request.Context(), param)
+	response, err := h.ProductUsecase.SearchProduct(c.Request.Context(), param)
 	if err != nil {
 		log.Logger.WithFields(logrus.Fields{
 			"param": param,
 		}).Errorf("h.ProductUsecase.SearchProduct got error %v", err)
 
+		writeError(c, err)
+
+		return
+	}
+
+	// next page url keeps every filter of this request and continues from the cursor, also after
+	// an offset page, so rows inserted meanwhile do not shift the next page
+	if response.NextCursor != nil {
+		query := c.Request.URL.Query()
+		query.Del("page")
+		query.Set("cursor", *response.NextCursor)
+
+		url := fmt.Sprintf("%s/v1/product/search?%s", c.Request.Host, query.Encode())
+		response.NextPageUrl = &url
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": response,
+	})
+}
+
+// handler stock reservation
+func (h *ProductHandler) StockReservationManagement(c *gin.Context) {
+	var param models.StockReservationParameter
+
+	if err := decodeBody(c, &param); err != nil {
+		log.Logger.Error(err.Error())
+
+		writeError(c, service.NewValidationError("invalid_input", "Invalid Input"))
+
+		return
+	}
+
+	if param.Action == "" {
+		log.Logger.Error("Missing required action parameter")
+
+		writeError(c, service.NewValidationError("missing_action", "Missing required action parameter"))
+
+		return
+	}
+
+	if err := validateStruct(&param); err != nil {
+		writeBindError(c, err)
+
+		return
+	}
+
+	switch param.Action {
+	case "reserve":
+		reservation, err := h.ProductUsecase.ReserveStock(c.Request.Context(), param.ProductID, param.Quantity)
+
+		if err != nil {
+			writeError(c, err)
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Successfully reserve stock.",
+			"reservation": reservation,
+		})
+
+		return
+	case "commit", "release":
+		var reservation *models.StockReservation
+		var err error
+
+		if param.Action == "commit" {
+			reservation, err = h.ProductUsecase.CommitReservation(c.Request.Context(), param.ReservationID)
+		} else {
+			reservation, err = h.ProductUsecase.ReleaseReservation(c.Request.Context(), param.ReservationID)
+		}
+
+		if err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"param": param,
+			}).Errorf("h.ProductUsecase %s reservation got error %v", param.Action, err)
+
+			writeError(c, err)
+
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{
-			"error_message": err.Error(),
+			"message":     fmt.Sprintf("Successfully %s reservation.", param.Action),
+			"reservation": reservation,
 		})
 
+		return
+
+	default:
+		log.Logger.Error("Invalid Action")
+		writeError(c, service.NewValidationError("invalid_action", "Invalid Action"))
+
+		return
+	}
+}
+
+// etag
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// ifMatchVersion prefers the If-Match header over the version sent in the body, 0 means unconditional
+func ifMatchVersion(c *gin.Context, bodyVersion int64) (int64, error) {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return bodyVersion, nil
+	}
+
+	ifMatch = strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+
+	return strconv.ParseInt(ifMatch, 10, 64)
+}
+
+// handler stock adjustment
+func (h *ProductHandler) AdjustProductStock(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": c.Param("id"),
+		}).Errorf("strconv.ParseInt got error %v", err)
+
+		writeError(c, service.NewValidationError("invalid_product_id", "Invalid Product ID"))
+
+		return
+	}
+
+	var param models.StockAdjustmentParameter
+
+	if err := c.ShouldBindJSON(&param); err != nil {
+		writeBindError(c, err)
+
+		return
+	}
+
+	movement, err := h.ProductUsecase.AdjustStock(c.Request.Context(), productID, &param)
+	if err != nil {
+		writeError(c, err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Successfully adjust product stock.",
+		"movement": movement,
+	})
+}
+
+func (h *ProductHandler) GetInventoryMovements(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": c.Param("id"),
+		}).Errorf("strconv.ParseInt got error %v", err)
+
+		writeError(c, service.NewValidationError("invalid_product_id", "Invalid Product ID"))
+
 		return
 	}
 
-	// next page url
-	totalPages := (totalCount + pageSize - 1) / pageSize
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 
-	var nextPageUrl *string
+	if page < 1 {
+		page = 1
+	}
 
-	if page < totalPages {
-		url := fmt.Sprintf("%s/v1/product/search?name%s&category=%s&min_price=%0.f&max_price=%0.f&page=%d&page_size=%d", c.Request.Host, name, category, minPrice, maxPrice, page+1, pageSize)
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
 
-		nextPageUrl = &url
+	movements, totalCount, err := h.ProductUsecase.GetInventoryMovements(c.Request.Context(), productID, page, pageSize)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+		}).Errorf("h.ProductUsecase.GetInventoryMovements got error %v", err)
+
+		writeError(c, err)
+
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data": models.SearchProductResponse{
-			Products:    products,
-			Page:        page,
-			PageSize:    pageSize,
-			TotalCount:  totalCount,
-			TotalPages:  totalPages,
-			NextPageUrl: nextPageUrl,
+		"data": models.InventoryMovementResponse{
+			Movements:  movements,
+			Page:       page,
+			PageSize:   pageSize,
+			TotalCount: totalCount,
+			TotalPages: (totalCount + pageSize - 1) / pageSize,
 		},
 	})
 }
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"product/cmd/product/service"
+	"product/infrastructure/log"
+	"product/middleware"
+	"product/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// handler product history, admin only since entries carry deleted products and who changed them
+func (h *ProductHandler) GetProductHistory(c *gin.Context) {
+	if !c.GetBool(middleware.IsAdminKey) {
+		writeError(c, service.NewError(service.KindForbidden, "admin_required", "product history needs the admin token", nil))
+
+		return
+	}
+
+	productID, ok := pathID(c, "invalid_product_id", "Invalid Product ID")
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	history, totalCount, err := h.ProductUsecase.GetProductHistory(c.Request.Context(), productID, page, pageSize)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+		}).Errorf("h.ProductUsecase.GetProductHistory got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": models.ProductHistoryResponse{
+			History:    history,
+			Page:       page,
+			PageSize:   pageSize,
+			TotalCount: totalCount,
+			TotalPages: (totalCount + pageSize - 1) / pageSize,
+		},
+	})
+}
+
+// getProductAsOf answers GET /v1/product/:id?as_of=, no ETag since the version is not the current one
+func (h *ProductHandler) getProductAsOf(c *gin.Context, productID int64, currency string, asOf time.Time, withDeleted bool) {
+	product, err := h.ProductUsecase.GetProductAsOf(c.Request.Context(), productID, currency, asOf, withDeleted)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+			"asOf":      asOf,
+		}).Errorf("h.ProductUsecase.GetProductAsOf got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success",
+		"product": product,
+	})
+}
@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"product/cmd/product/service"
+	"product/infrastructure/log"
+	"product/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	importFormatCSV    = "csv"
+	importFormatNDJSON = "ndjson"
+
+	maxImportBytes = 10 << 20
+	maxImportRows  = 10000
+)
+
+// errImportTooLarge stops parsing once the file passes maxImportRows
+var errImportTooLarge = fmt.Errorf("import accepts at most %d rows", maxImportRows)
+
+// ImportProducts takes a csv or ndjson file, either as the raw body or as the "file" field of a multipart form.
+// the format comes from ?format=, the content type or the file extension, in that order
+func (h *ProductHandler) ImportProducts(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		writeError(c, service.NewValidationError("invalid_dry_run", "dry_run must be true or false"))
+
+		return
+	}
+
+	createCategories, err := strconv.ParseBool(c.DefaultQuery("create_categories", "false"))
+	if err != nil {
+		writeError(c, service.NewValidationError("invalid_create_categories", "create_categories must be true or false"))
+
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+
+	body, format, err := importFile(c)
+	if err != nil {
+		log.Logger.Errorf("importFile got error %v", err)
+
+		writeError(c, service.NewValidationError("invalid_import_file", "Import file is missing or too large"))
+
+		return
+	}
+	defer body.Close()
+
+	var rows []models.ProductImportRow
+
+	switch format {
+	case importFormatCSV:
+		rows, err = parseImportCSV(body)
+	case importFormatNDJSON:
+		rows, err = parseImportNDJSON(body)
+	default:
+		writeError(c, service.NewValidationError("unsupported_import_format", "format must be csv or ndjson"))
+
+		return
+	}
+
+	if err != nil {
+		log.Logger.Errorf("parse import %s got error %v", format, err)
+
+		writeError(c, service.NewValidationError("invalid_import_file", err.Error()))
+
+		return
+	}
+
+	for i := range rows {
+		if rows[i].Error == "" {
+			rows[i].Error = importRowError(validateStruct(&rows[i]))
+		}
+	}
+
+	report, err := h.ProductUsecase.ImportProducts(c.Request.Context(), &models.ProductImportParameter{
+		Rows:             rows,
+		DryRun:           dryRun,
+		CreateCategories: createCategories,
+	})
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"rows": len(rows),
+		}).Errorf("h.ProductUsecase.ImportProducts got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": report,
+	})
+}
+
+func importFile(c *gin.Context) (io.ReadCloser, string, error) {
+	format := strings.ToLower(c.Query("format"))
+	contentType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+
+	if contentType != "multipart/form-data" {
+		if format == "" {
+			format = importFormatFromContentType(contentType)
+		}
+
+		return c.Request.Body, format, nil
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return nil, "", err
+	}
+
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+		if format == "jsonl" {
+			format = importFormatNDJSON
+		}
+	}
+
+	if format == "" {
+		format = importFormatFromContentType(fileHeader.Header.Get("Content-Type"))
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return file, format, nil
+}
+
+func importFormatFromContentType(contentType string) string {
+	switch contentType {
+	case "text/csv":
+		return importFormatCSV
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return importFormatNDJSON
+	default:
+		return ""
+	}
+}
+
+// parseImportCSV needs a header row, columns are matched by name and unknown ones are ignored.
+// a cell that does not parse fails its row, a broken file fails the whole import
+func parseImportCSV(r io.Reader) ([]models.ProductImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	for _, required := range []string{"name", "price", "category"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header is missing the %q column", required)
+		}
+	}
+
+	var rows []models.ProductImportRow
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rows) == maxImportRows {
+			return nil, errImportTooLarge
+		}
+
+		line, _ := reader.FieldPos(0)
+		cell := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+
+			return strings.TrimSpace(record[i])
+		}
+
+		row := models.ProductImportRow{
+			Line:        line,
+			Name:        cell("name"),
+			Description: cell("description"),
+			Category:    cell("category"),
+			Currency:    strings.ToUpper(cell("currency")),
+		}
+
+		if row.Price, err = models.ParseDecimal(cell("price")); err != nil {
+			row.Error = "price must be a number with at most four decimal places"
+		} else if stock := cell("stock"); stock != "" {
+			if row.Stock, err = strconv.Atoi(stock); err != nil {
+				row.Error = "stock must be an integer"
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// parseImportNDJSON reads one product object per line, blank lines are skipped
+func parseImportNDJSON(r io.Reader) ([]models.ProductImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	var rows []models.ProductImportRow
+
+	for line := 1; scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		if len(rows) == maxImportRows {
+			return nil, errImportTooLarge
+		}
+
+		row := models.ProductImportRow{}
+		if err := json.Unmarshal(raw, &row); err != nil {
+			row.Error = "line is not a valid product object"
+		}
+
+		row.Line = line
+		rows = append(rows, row)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// importRowError joins every failing rule of a row into its report reason
+func importRowError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err.Error()
+	}
+
+	messages := make([]string, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		messages = append(messages, fieldErrorMessage(fieldErr))
+	}
+
+	return strings.Join(messages, "; ")
+}
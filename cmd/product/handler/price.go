@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+	"product/cmd/product/service"
+	"product/infrastructure/log"
+	"product/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// handler product price list
+func (h *ProductHandler) SetProductPrice(c *gin.Context) {
+	productID, currency, ok := pricePathParams(c)
+	if !ok {
+		return
+	}
+
+	// the currency comes from the path, it is set first so a body without it still validates
+	param := models.ProductPrice{Currency: currency}
+
+	if err := c.ShouldBindJSON(&param); err != nil {
+		writeBindError(c, err)
+
+		return
+	}
+
+	param.ProductID = productID
+	param.Currency = currency
+
+	price, err := h.ProductUsecase.SetProductPrice(c.Request.Context(), &param)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+			"currency":  currency,
+		}).Errorf("h.ProductUsecase.SetProductPrice got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Successfully set product price.",
+		"price":   price,
+	})
+}
+
+func (h *ProductHandler) DeleteProductPrice(c *gin.Context) {
+	productID, currency, ok := pricePathParams(c)
+	if !ok {
+		return
+	}
+
+	err := h.ProductUsecase.DeleteProductPrice(c.Request.Context(), productID, currency)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+			"currency":  currency,
+		}).Errorf("h.ProductUsecase.DeleteProductPrice got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// pricePathParams parses :id and :currency, on failure the error response is already written
+func pricePathParams(c *gin.Context) (int64, string, bool) {
+	productID, ok := pathID(c, "invalid_product_id", "Invalid Product ID")
+	if !ok {
+		return 0, "", false
+	}
+
+	currency := strings.ToUpper(c.Param("currency"))
+	if err := validateVar(currency, "iso4217"); err != nil {
+		writeError(c, service.NewValidationError("invalid_currency", "currency must be an ISO 4217 code such as USD"))
+
+		return 0, "", false
+	}
+
+	return productID, currency, true
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"product/cmd/product/service"
+	"product/middleware"
+	"product/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryList accepts both repeated keys and comma separated values, ?ids=1&ids=2 and ?ids=1,2 are the same
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+
+	for _, raw := range c.QueryArray(key) {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+
+	return values
+}
+
+func queryInt64List(c *gin.Context, key string) ([]int64, bool) {
+	var values []int64
+
+	for _, raw := range queryList(c, key) {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			writeError(c, service.NewValidationError("invalid_"+key, key+" must be a comma separated list of integers"))
+
+			return nil, false
+		}
+
+		values = append(values, value)
+	}
+
+	return values, true
+}
+
+func queryDecimalList(c *gin.Context, key string) ([]models.Decimal, bool) {
+	var values []models.Decimal
+
+	for _, raw := range queryList(c, key) {
+		value, err := models.ParseDecimal(raw)
+		if err != nil {
+			writeError(c, service.NewValidationError("invalid_"+key, key+" must be a comma separated list of numbers"))
+
+			return nil, false
+		}
+
+		values = append(values, value)
+	}
+
+	return values, true
+}
+
+// queryDecimal returns nil when the key is absent, so an explicit zero stays distinguishable from unset
+func queryDecimal(c *gin.Context, key string) (*models.Decimal, bool) {
+	raw, ok := c.GetQuery(key)
+	if !ok || raw == "" {
+		return nil, true
+	}
+
+	value, err := models.ParseDecimal(raw)
+	if err != nil {
+		writeError(c, service.NewValidationError("invalid_"+key, key+" must be a number with at most four decimal places"))
+
+		return nil, false
+	}
+
+	return &value, true
+}
+
+// queryCurrency reads an ISO 4217 code such as EUR, empty leaves prices in each product's own currency
+func queryCurrency(c *gin.Context) (string, bool) {
+	currency := strings.ToUpper(strings.TrimSpace(c.Query("currency")))
+	if currency == "" {
+		return "", true
+	}
+
+	if err := validateVar(currency, "iso4217"); err != nil {
+		writeError(c, service.NewValidationError("invalid_currency", "currency must be an ISO 4217 code such as USD"))
+
+		return "", false
+	}
+
+	return currency, true
+}
+
+// queryTime reads an RFC 3339 timestamp such as 2024-05-14T09:30:00Z, nil when the key is absent
+func queryTime(c *gin.Context, key string) (*time.Time, bool) {
+	raw, ok := c.GetQuery(key)
+	if !ok || raw == "" {
+		return nil, true
+	}
+
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		writeError(c, service.NewValidationError("invalid_"+key, key+" must be an RFC 3339 timestamp such as 2024-05-14T09:30:00Z"))
+
+		return nil, false
+	}
+
+	return &value, true
+}
+
+func queryBool(c *gin.Context, key string) (*bool, bool) {
+	raw, ok := c.GetQuery(key)
+	if !ok || raw == "" {
+		return nil, true
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		writeError(c, service.NewValidationError("invalid_"+key, key+" must be true or false"))
+
+		return nil, false
+	}
+
+	return &value, true
+}
+
+// searchFilters reads the filters shared by search and export into param, false means an error was written
+func searchFilters(c *gin.Context, param *models.SearchProductParameter) bool {
+	param.Name = c.Query("name")
+	param.Category = c.Query("category")
+
+	var ok bool
+
+	if param.Currency, ok = queryCurrency(c); !ok {
+		return false
+	}
+
+	if param.MinPrice, ok = queryDecimal(c, "min_price"); !ok {
+		return false
+	}
+
+	if param.MaxPrice, ok = queryDecimal(c, "max_price"); !ok {
+		return false
+	}
+
+	if param.InStock, ok = queryBool(c, "in_stock"); !ok {
+		return false
+	}
+
+	if param.CategoryIDs, ok = queryInt64List(c, "category_id"); !ok {
+		return false
+	}
+
+	if param.IDs, ok = queryInt64List(c, "ids"); !ok {
+		return false
+	}
+
+	if param.ExcludeIDs, ok = queryInt64List(c, "exclude_ids"); !ok {
+		return false
+	}
+
+	param.IncludeDeleted, ok = includeDeleted(c)
+
+	return ok
+}
+
+// includeDeleted reads the admin only include_deleted flag, asking for it without the admin token is a 403
+func includeDeleted(c *gin.Context) (bool, bool) {
+	include, ok := queryBool(c, "include_deleted")
+	if !ok || include == nil || !*include {
+		return false, ok
+	}
+
+	if !c.GetBool(middleware.IsAdminKey) {
+		writeError(c, service.NewError(service.KindForbidden, "admin_required", "include_deleted needs the admin token", nil))
+
+		return false, false
+	}
+
+	return true, true
+}
@@ -0,0 +1,381 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"product/cmd/product/service"
+	"product/infrastructure/log"
+	"product/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// resource handler product
+func (h *ProductHandler) CreateProduct(c *gin.Context) {
+	var param models.Product
+
+	if err := c.ShouldBindJSON(&param); err != nil {
+		writeBindError(c, err)
+
+		return
+	}
+
+	productID, err := h.ProductUsecase.CreateNewProduct(c.Request.Context(), &param)
+	if err != nil {
+		writeError(c, err)
+
+		return
+	}
+
+	setETag(c, param.Version)
+	c.Header("Location", fmt.Sprintf("/v1/products/%d", productID))
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Successfully create new product.",
+		"product": param,
+	})
+}
+
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	productID, ok := pathID(c, "invalid_product_id", "Invalid Product ID")
+	if !ok {
+		return
+	}
+
+	var param models.Product
+
+	if err := c.ShouldBindJSON(&param); err != nil {
+		writeBindError(c, err)
+
+		return
+	}
+
+	h.editProduct(c, productID, &param)
+}
+
+// PatchProduct only touches the fields present in the request body
+func (h *ProductHandler) PatchProduct(c *gin.Context) {
+	productID, ok := pathID(c, "invalid_product_id", "Invalid Product ID")
+	if !ok {
+		return
+	}
+
+	var patch models.ProductPatch
+
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		writeBindError(c, err)
+
+		return
+	}
+
+	version, err := ifMatchVersion(c, patch.Version)
+	if err != nil {
+		writeError(c, service.NewValidationError("invalid_if_match", "Invalid If-Match header"))
+
+		return
+	}
+
+	patch.Version = version
+
+	product, err := h.ProductUsecase.PatchProduct(c.Request.Context(), productID, &patch)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+		}).Errorf("h.ProductUsecase.PatchProduct got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	setETag(c, product.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Successfully edit product.",
+		"product": product,
+	})
+}
+
+func (h *ProductHandler) editProduct(c *gin.Context, productID int64, param *models.Product) {
+	version, err := ifMatchVersion(c, param.Version)
+	if err != nil {
+		writeError(c, service.NewValidationError("invalid_if_match", "Invalid If-Match header"))
+
+		return
+	}
+
+	param.ID = productID
+	param.Version = version
+
+	product, err := h.ProductUsecase.EditProduct(c.Request.Context(), param)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"param": param,
+		}).Errorf("h.ProductUsecase.EditProduct got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	setETag(c, product.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Successfully edit product.",
+		"product": product,
+	})
+}
+
+func (h *ProductHandler) BatchGetProducts(c *gin.Context) {
+	var param models.BatchGetProductParameter
+
+	if err := c.ShouldBindJSON(&param); err != nil {
+		writeBindError(c, err)
+
+		return
+	}
+
+	results, err := h.ProductUsecase.BatchGetProducts(c.Request.Context(), param.IDs)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productIDs": param.IDs,
+		}).Errorf("h.ProductUsecase.BatchGetProducts got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": results,
+	})
+}
+
+func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	productID, ok := pathID(c, "invalid_product_id", "Invalid Product ID")
+	if !ok {
+		return
+	}
+
+	err := h.ProductUsecase.DeleteProduct(c.Request.Context(), productID)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+		}).Errorf("h.ProductUsecase.DeleteProduct got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ProductHandler) RestoreProduct(c *gin.Context) {
+	productID, ok := pathID(c, "invalid_product_id", "Invalid Product ID")
+	if !ok {
+		return
+	}
+
+	product, err := h.ProductUsecase.RestoreProduct(c.Request.Context(), productID)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+		}).Errorf("h.ProductUsecase.RestoreProduct got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	setETag(c, product.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Successfully restore product.",
+		"product": product,
+	})
+}
+
+// resource handler product category
+func (h *ProductHandler) CreateProductCategory(c *gin.Context) {
+	var param models.ProductCategory
+
+	if err := c.ShouldBindJSON(&param); err != nil {
+		writeBindError(c, err)
+
+		return
+	}
+
+	productCategoryID, err := h.ProductUsecase.CreateNewProductCategory(c.Request.Context(), &param)
+	if err != nil {
+		writeError(c, err)
+
+		return
+	}
+
+	setETag(c, param.Version)
+	c.Header("Location", fmt.Sprintf("/v1/product-categories/%d", productCategoryID))
+	c.JSON(http.StatusCreated, gin.H{
+		"message":          "Successfully create new product category.",
+		"product_category": param,
+	})
+}
+
+func (h *ProductHandler) UpdateProductCategory(c *gin.Context) {
+	productCategoryID, ok := pathID(c, "invalid_product_category_id", "Invalid Product Category ID")
+	if !ok {
+		return
+	}
+
+	var param models.ProductCategory
+
+	if err := c.ShouldBindJSON(&param); err != nil {
+		writeBindError(c, err)
+
+		return
+	}
+
+	h.editProductCategory(c, productCategoryID, &param)
+}
+
+func (h *ProductHandler) PatchProductCategory(c *gin.Context) {
+	productCategoryID, ok := pathID(c, "invalid_product_category_id", "Invalid Product Category ID")
+	if !ok {
+		return
+	}
+
+	current, err := h.ProductUsecase.GetProductCategoryByID(c.Request.Context(), productCategoryID)
+	if err != nil {
+		writeError(c, err)
+
+		return
+	}
+
+	param := *current
+	if err := c.ShouldBindJSON(&param); err != nil {
+		writeBindError(c, err)
+
+		return
+	}
+
+	h.editProductCategory(c, productCategoryID, &param)
+}
+
+func (h *ProductHandler) editProductCategory(c *gin.Context, productCategoryID int64, param *models.ProductCategory) {
+	version, err := ifMatchVersion(c, param.Version)
+	if err != nil {
+		writeError(c, service.NewValidationError("invalid_if_match", "Invalid If-Match header"))
+
+		return
+	}
+
+	param.ID = productCategoryID
+	param.Version = version
+
+	productCategory, err := h.ProductUsecase.EditProductCategory(c.Request.Context(), param)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"param": param,
+		}).Errorf("h.ProductUsecase.EditProductCategory got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	setETag(c, productCategory.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Successfully edit product category.",
+		"product_category": productCategory,
+	})
+}
+
+func (h *ProductHandler) DeleteProductCategory(c *gin.Context) {
+	productCategoryID, ok := pathID(c, "invalid_product_category_id", "Invalid Product Category ID")
+	if !ok {
+		return
+	}
+
+	targetCategoryID, _ := strconv.ParseInt(c.Query("target_category_id"), 10, 64)
+
+	err := h.ProductUsecase.DeleteProductCategory(c.Request.Context(), productCategoryID, &models.DeleteProductCategoryParameter{
+		Mode:             c.Query("mode"),
+		TargetCategoryID: targetCategoryID,
+	})
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productCategoryID": productCategoryID,
+		}).Errorf("h.ProductUsecase.DeleteProductCategory got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ProductHandler) RestoreProductCategory(c *gin.Context) {
+	productCategoryID, ok := pathID(c, "invalid_product_category_id", "Invalid Product Category ID")
+	if !ok {
+		return
+	}
+
+	productCategory, err := h.ProductUsecase.RestoreProductCategory(c.Request.Context(), productCategoryID)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productCategoryID": productCategoryID,
+		}).Errorf("h.ProductUsecase.RestoreProductCategory got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	setETag(c, productCategory.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Successfully restore product category.",
+		"product_category": productCategory,
+	})
+}
+
+func (h *ProductHandler) GetProductCategoryTree(c *gin.Context) {
+	productCategoryID, ok := pathID(c, "invalid_product_category_id", "Invalid Product Category ID")
+	if !ok {
+		return
+	}
+
+	tree, err := h.ProductUsecase.GetProductCategoryTree(c.Request.Context(), productCategoryID)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productCategoryID": productCategoryID,
+		}).Errorf("h.ProductUsecase.GetProductCategoryTree got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": tree,
+	})
+}
+
+// pathID parses the :id path param, on failure the error response is already written
+func pathID(c *gin.Context, code string, message string) (int64, bool) {
+	return pathParamID(c, "id", code, message)
+}
+
+// pathParamID parses the named path param, on failure the error response is already written
+func pathParamID(c *gin.Context, name string, code string, message string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			name: c.Param(name),
+		}).Errorf("strconv.ParseInt got error %v", err)
+
+		writeError(c, service.NewValidationError(code, message))
+
+		return 0, false
+	}
+
+	return id, true
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"product/infrastructure/log"
+	"product/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// handler product price schedule
+func (h *ProductHandler) GetPriceSchedules(c *gin.Context) {
+	productID, ok := pathID(c, "invalid_product_id", "Invalid Product ID")
+	if !ok {
+		return
+	}
+
+	schedules, err := h.ProductUsecase.GetPriceSchedules(c.Request.Context(), productID)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+		}).Errorf("h.ProductUsecase.GetPriceSchedules got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": schedules,
+	})
+}
+
+func (h *ProductHandler) CreatePriceSchedule(c *gin.Context) {
+	productID, ok := pathID(c, "invalid_product_id", "Invalid Product ID")
+	if !ok {
+		return
+	}
+
+	var param models.PriceSchedule
+
+	if err := c.ShouldBindJSON(&param); err != nil {
+		writeBindError(c, err)
+
+		return
+	}
+
+	// ids come from the path and the database, never from the body
+	param.ID = 0
+	param.ProductID = productID
+
+	scheduleID, err := h.ProductUsecase.CreatePriceSchedule(c.Request.Context(), &param)
+	if err != nil {
+		writeError(c, err)
+
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/v1/product/%d/price-schedules/%d", productID, scheduleID))
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        "Successfully create new price schedule.",
+		"price_schedule": param,
+	})
+}
+
+func (h *ProductHandler) DeletePriceSchedule(c *gin.Context) {
+	productID, ok := pathID(c, "invalid_product_id", "Invalid Product ID")
+	if !ok {
+		return
+	}
+
+	scheduleID, ok := pathParamID(c, "schedule_id", "invalid_schedule_id", "Invalid Price Schedule ID")
+	if !ok {
+		return
+	}
+
+	err := h.ProductUsecase.DeletePriceSchedule(c.Request.Context(), productID, scheduleID)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID":  productID,
+			"scheduleID": scheduleID,
+		}).Errorf("h.ProductUsecase.DeletePriceSchedule got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"product/cmd/product/service"
+	"product/infrastructure/log"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// RegisterValidators sets gin's validator up for the models. category existence is not a binding rule,
+// the service checks it with the request context and can tell a missing category from a failed lookup
+func (h *ProductHandler) RegisterValidators() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("gin validator engine is not go-playground/validator")
+	}
+
+	// report fields by their json name so clients can match them to the payload
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
+
+	return nil
+}
+
+// writeBindError answers 422 with every failing field, anything else is a malformed body
+func writeBindError(c *gin.Context, err error) {
+	log.Logger.Error(err.Error())
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		writeError(c, service.NewValidationError("invalid_input", "Invalid Input"))
+
+		return
+	}
+
+	fields := make([]service.FieldError, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		fields = append(fields, service.FieldError{
+			Field:   fieldErr.Field(),
+			Rule:    fieldErr.Tag(),
+			Message: fieldErrorMessage(fieldErr),
+		})
+	}
+
+	writeError(c, service.NewFieldValidationError(fields))
+}
+
+func fieldErrorMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", fieldErr.Field())
+	case "required_if":
+		return fmt.Sprintf("%s is required for this action", fieldErr.Field())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s characters", fieldErr.Field(), fieldErr.Param())
+	case "min":
+		return fmt.Sprintf("%s must be at least %s characters", fieldErr.Field(), fieldErr.Param())
+	case "gte":
+		return fmt.Sprintf("%s must be greater than or equal to %s", fieldErr.Field(), fieldErr.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", fieldErr.Field(), fieldErr.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", fieldErr.Field(), fieldErr.Param())
+	case "iso4217":
+		return fmt.Sprintf("%s must be an ISO 4217 currency code such as USD", fieldErr.Field())
+	default:
+		return fmt.Sprintf("%s failed the %s rule", fieldErr.Field(), fieldErr.Tag())
+	}
+}
+
+// decodeBody reads the json body without validating it, action endpoints validate per action.
+// the raw body is kept on the context so it can be decoded more than once
+func decodeBody(c *gin.Context, obj interface{}) error {
+	var body []byte
+
+	if cached, ok := c.Get(gin.BodyBytesKey); ok {
+		body, _ = cached.([]byte)
+	} else {
+		raw, err := c.GetRawData()
+		if err != nil {
+			return err
+		}
+
+		body = raw
+		c.Set(gin.BodyBytesKey, body)
+	}
+
+	return json.Unmarshal(body, obj)
+}
+
+func validateStruct(obj interface{}) error {
+	return binding.Validator.ValidateStruct(obj)
+}
+
+func validateVar(value interface{}, tag string) error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("gin validator engine is not go-playground/validator")
+	}
+
+	return v.Var(value, tag)
+}
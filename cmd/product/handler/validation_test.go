@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+type fieldErrorResponse struct {
+	ErrorCode string              `json:"error_code"`
+	Fields    []map[string]string `json:"fields"`
+}
+
+func postProductManagement(t *testing.T, h *ProductHandler, body string) (int, fieldErrorResponse) {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/product", strings.NewReader(body))
+
+	h.ProductManagement(c)
+
+	var response fieldErrorResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%v in %s", err, recorder.Body.String())
+	}
+
+	return recorder.Code, response
+}
+
+// the service checks answer with the same 422 and fields as a failed binding, every failing field at once
+func TestProductFieldErrorsMatchBindErrors(t *testing.T) {
+	h, mock := newTestHandler(t)
+	if err := h.RegisterValidators(); err != nil {
+		t.Fatal(err)
+	}
+
+	status, bindResponse := postProductManagement(t, h, `{"action":"add","price":"10.00","category_id":1}`)
+	if status != http.StatusUnprocessableEntity || len(bindResponse.Fields) != 1 || bindResponse.Fields[0]["field"] != "name" {
+		t.Fatalf("got %d %+v, want 422 for name", status, bindResponse)
+	}
+
+	mock.ExpectQuery(`FROM "product_category"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	status, serviceResponse := postProductManagement(t, h, `{"action":"add","name":"keyboard","price":"10.001","currency":"USD","category_id":99}`)
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("got %d %+v, want 422", status, serviceResponse)
+	}
+
+	if serviceResponse.ErrorCode != bindResponse.ErrorCode {
+		t.Errorf("got error_code %q, want %q like the bind error", serviceResponse.ErrorCode, bindResponse.ErrorCode)
+	}
+
+	rules := map[string]string{}
+	for _, field := range serviceResponse.Fields {
+		if len(field) != len(bindResponse.Fields[0]) || field["message"] == "" {
+			t.Errorf("got field %+v, want the keys of %+v", field, bindResponse.Fields[0])
+		}
+
+		rules[field["field"]] = field["rule"]
+	}
+
+	if len(rules) != 2 || rules["price"] != "price_precision" || rules["category_id"] != "category_exists" {
+		t.Errorf("got fields %+v, want price and category_id", serviceResponse.Fields)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStockReservationBindErrors(t *testing.T) {
+	h, mock := newTestHandler(t)
+	if err := h.RegisterValidators(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+		fields []string
+	}{
+		{
+			name:   "malformed body",
+			body:   `{"action":"reserve","quantity":"two"}`,
+			status: http.StatusBadRequest,
+			code:   "invalid_input",
+		},
+		{
+			name:   "reserve without product and quantity",
+			body:   `{"action":"reserve"}`,
+			status: http.StatusUnprocessableEntity,
+			code:   "validation_failed",
+			fields: []string{"product_id", "quantity"},
+		},
+		{
+			name:   "negative quantity",
+			body:   `{"action":"reserve","product_id":1,"quantity":-1}`,
+			status: http.StatusUnprocessableEntity,
+			code:   "validation_failed",
+			fields: []string{"quantity"},
+		},
+		{
+			name:   "release without reservation",
+			body:   `{"action":"release"}`,
+			status: http.StatusUnprocessableEntity,
+			code:   "validation_failed",
+			fields: []string{"reservation_id"},
+		},
+		{
+			name:   "unknown action",
+			body:   `{"action":"hold"}`,
+			status: http.StatusBadRequest,
+			code:   "invalid_action",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/product/reservation", strings.NewReader(test.body))
+
+			h.StockReservationManagement(c)
+
+			var response fieldErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("%v in %s", err, recorder.Body.String())
+			}
+
+			if recorder.Code != test.status || response.ErrorCode != test.code {
+				t.Fatalf("got %d %q, want %d %q: %s", recorder.Code, response.ErrorCode, test.status, test.code, recorder.Body.String())
+			}
+
+			if len(response.Fields) != len(test.fields) {
+				t.Fatalf("got fields %+v, want %v", response.Fields, test.fields)
+			}
+
+			for i, field := range test.fields {
+				if response.Fields[i]["field"] != field {
+					t.Errorf("got field %q, want %q", response.Fields[i]["field"], field)
+				}
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
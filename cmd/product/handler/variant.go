@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"product/cmd/product/service"
+	"product/infrastructure/log"
+	"product/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// handler product variant
+func (h *ProductHandler) GetProductVariants(c *gin.Context) {
+	productID, ok := pathID(c, "invalid_product_id", "Invalid Product ID")
+	if !ok {
+		return
+	}
+
+	variants, err := h.ProductUsecase.GetProductVariants(c.Request.Context(), productID)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+		}).Errorf("h.ProductUsecase.GetProductVariants got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": variants,
+	})
+}
+
+func (h *ProductHandler) GetProductVariantByID(c *gin.Context) {
+	productID, variantID, ok := variantPathIDs(c)
+	if !ok {
+		return
+	}
+
+	variant, err := h.ProductUsecase.GetProductVariantByID(c.Request.Context(), productID, variantID)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+			"variantID": variantID,
+		}).Errorf("h.ProductUsecase.GetProductVariantByID got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	setETag(c, variant.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Success",
+		"variant": variant,
+	})
+}
+
+func (h *ProductHandler) CreateProductVariant(c *gin.Context) {
+	productID, ok := pathID(c, "invalid_product_id", "Invalid Product ID")
+	if !ok {
+		return
+	}
+
+	var param models.ProductVariant
+
+	if err := c.ShouldBindJSON(&param); err != nil {
+		writeBindError(c, err)
+
+		return
+	}
+
+	// ids come from the path and the database, never from the body
+	param.ID = 0
+	param.ProductID = productID
+
+	variantID, err := h.ProductUsecase.CreateProductVariant(c.Request.Context(), &param)
+	if err != nil {
+		writeError(c, err)
+
+		return
+	}
+
+	setETag(c, param.Version)
+	c.Header("Location", fmt.Sprintf("/v1/product/%d/variants/%d", productID, variantID))
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Successfully create new product variant.",
+		"variant": param,
+	})
+}
+
+func (h *ProductHandler) UpdateProductVariant(c *gin.Context) {
+	productID, variantID, ok := variantPathIDs(c)
+	if !ok {
+		return
+	}
+
+	var param models.ProductVariant
+
+	if err := c.ShouldBindJSON(&param); err != nil {
+		writeBindError(c, err)
+
+		return
+	}
+
+	version, err := ifMatchVersion(c, param.Version)
+	if err != nil {
+		writeError(c, service.NewValidationError("invalid_if_match", "Invalid If-Match header"))
+
+		return
+	}
+
+	param.ID = variantID
+	param.ProductID = productID
+	param.Version = version
+
+	variant, err := h.ProductUsecase.UpdateProductVariant(c.Request.Context(), &param)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"param": param,
+		}).Errorf("h.ProductUsecase.UpdateProductVariant got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	setETag(c, variant.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Successfully edit product variant.",
+		"variant": variant,
+	})
+}
+
+func (h *ProductHandler) DeleteProductVariant(c *gin.Context) {
+	productID, variantID, ok := variantPathIDs(c)
+	if !ok {
+		return
+	}
+
+	err := h.ProductUsecase.DeleteProductVariant(c.Request.Context(), productID, variantID)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+			"variantID": variantID,
+		}).Errorf("h.ProductUsecase.DeleteProductVariant got error %v", err)
+
+		writeError(c, err)
+
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// variantPathIDs parses :id and :variant_id, on failure the error response is already written
+func variantPathIDs(c *gin.Context) (int64, int64, bool) {
+	productID, ok := pathID(c, "invalid_product_id", "Invalid Product ID")
+	if !ok {
+		return 0, 0, false
+	}
+
+	variantID, ok := pathParamID(c, "variant_id", "invalid_variant_id", "Invalid Variant ID")
+	if !ok {
+		return 0, 0, false
+	}
+
+	return productID, variantID, true
+}
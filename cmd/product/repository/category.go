@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"product/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// categories form a tree through parent_id, path holds the ancestor ids down to the category itself
+// such as /1/4/9/ so a whole subtree is a single path LIKE '/1/4/%' lookup
+
+func (r *ProductRepository) InsertNewProductCategory(ctx context.Context, productCategory *models.ProductCategory) (int64, error) {
+	productCategory.Version = 1
+
+	err := r.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		parentPath := "/"
+
+		if productCategory.ParentID != nil {
+			parent, err := lockCategory(tx, *productCategory.ParentID, "SHARE")
+			if err != nil {
+				return err
+			}
+
+			parentPath = parent.Path
+		}
+
+		err := tx.Table("product_category").Create(productCategory).Error
+		if err != nil {
+			return err
+		}
+
+		productCategory.Path = fmt.Sprintf("%s%d/", parentPath, productCategory.ID)
+
+		return tx.Table("product_category").Where("id = ?", productCategory.ID).Update("path", productCategory.Path).Error
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return productCategory.ID, nil
+}
+
+// UpdateProductCategory writes name and parent with optimistic locking, a new parent moves the whole subtree
+func (r *ProductRepository) UpdateProductCategory(ctx context.Context, productCategory *models.ProductCategory) (*models.ProductCategory, error) {
+	err := r.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current, err := lockCategory(tx, productCategory.ID, "UPDATE")
+		if err != nil {
+			return err
+		}
+
+		if productCategory.Version > 0 && productCategory.Version != current.Version {
+			return models.ErrVersionConflict
+		}
+
+		if !sameParent(current.ParentID, productCategory.ParentID) {
+			err = moveCategorySubtree(tx, current, productCategory.ParentID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Table("product_category").Where("id = ?", productCategory.ID).Updates(map[string]interface{}{
+			"name":      productCategory.Name,
+			"parent_id": productCategory.ParentID,
+			"version":   gorm.Expr("version + 1"),
+		}).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindProductCategoryByID(ctx, productCategory.ID)
+}
+
+// DeleteProductCategory soft deletes the category, a category that still has live products or children
+// needs param.Mode: reassign moves both under the target, cascade soft deletes the whole subtree with its products.
+// everything a cascade takes along shares the category's deleted_at so RestoreProductCategory can bring back exactly that.
+// the returned ids are the products that changed
+func (r *ProductRepository) DeleteProductCategory(ctx context.Context, productCategoryID int64, param *models.DeleteProductCategoryParameter, now time.Time) ([]int64, error) {
+	var productIDs []int64
+
+	err := r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		productCategory, err := lockCategory(tx, productCategoryID, "UPDATE")
+		if err != nil {
+			return err
+		}
+
+		switch param.Mode {
+		case models.CategoryDeleteModeReassign:
+			target, err := lockCategory(tx, param.TargetCategoryID, "SHARE")
+			if err != nil {
+				return err
+			}
+
+			if strings.HasPrefix(target.Path, productCategory.Path) {
+				return models.ErrCategoryCycle
+			}
+
+			err = audit.trackWhere("category_id = ? AND deleted_at IS NULL", productCategoryID)
+			if err != nil {
+				return err
+			}
+
+			err = tx.Raw("UPDATE product SET category_id = ?, version = version + 1 WHERE category_id = ? AND deleted_at IS NULL RETURNING id", target.ID, productCategoryID).Scan(&productIDs).Error
+			if err != nil {
+				return err
+			}
+
+			var children []models.ProductCategory
+			err = tx.Table("product_category").Where("parent_id = ?", productCategoryID).Find(&children).Error
+			if err != nil {
+				return err
+			}
+
+			for i := range children {
+				err = moveCategorySubtree(tx, &children[i], &target.ID)
+				if err != nil {
+					return err
+				}
+
+				err = tx.Table("product_category").Where("id = ?", children[i].ID).Updates(map[string]interface{}{
+					"parent_id": target.ID,
+					"version":   gorm.Expr("version + 1"),
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+		case models.CategoryDeleteModeCascade:
+			err = audit.trackWhere("deleted_at IS NULL AND category_id IN (SELECT id FROM product_category WHERE path LIKE ?)", productCategory.Path+"%")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Raw("UPDATE product SET deleted_at = ?, version = version + 1 WHERE deleted_at IS NULL AND category_id IN (SELECT id FROM product_category WHERE path LIKE ?) RETURNING id", now, productCategory.Path+"%").Scan(&productIDs).Error
+			if err != nil {
+				return err
+			}
+
+			err = tx.Exec("UPDATE product_category SET deleted_at = ?, version = version + 1 WHERE deleted_at IS NULL AND path LIKE ?", now, productCategory.Path+"%").Error
+			if err != nil {
+				return err
+			}
+		default:
+			var count int64
+			err = tx.Raw("SELECT (SELECT count(*) FROM product WHERE category_id = ? AND deleted_at IS NULL) + (SELECT count(*) FROM product_category WHERE parent_id = ? AND deleted_at IS NULL)", productCategoryID, productCategoryID).Scan(&count).Error
+			if err != nil {
+				return err
+			}
+
+			if count > 0 {
+				return models.ErrCategoryNotEmpty
+			}
+		}
+
+		return tx.Table("product_category").Where("id = ?", productCategoryID).Update("deleted_at", now).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return productIDs, nil
+}
+
+// RestoreProductCategory clears deleted_at together with the categories and products a cascade delete took along,
+// anything deleted on its own before that stays deleted. the parent has to be live
+func (r *ProductRepository) RestoreProductCategory(ctx context.Context, productCategoryID int64) (*models.ProductCategory, []int64, error) {
+	var productIDs []int64
+
+	err := r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		var productCategory models.ProductCategory
+		err := tx.Unscoped().Table("product_category").Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", productCategoryID).Take(&productCategory).Error
+		if err != nil {
+			return err
+		}
+
+		if !productCategory.DeletedAt.Valid {
+			return nil
+		}
+
+		if productCategory.ParentID != nil {
+			_, err = lockCategory(tx, *productCategory.ParentID, "SHARE")
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return models.ErrCategoryDeleted
+				}
+
+				return err
+			}
+		}
+
+		deletedAt := productCategory.DeletedAt.Time
+		subtree := productCategory.Path + "%"
+
+		err = tx.Exec("UPDATE product_category SET deleted_at = NULL, version = version + 1 WHERE path LIKE ? AND deleted_at = ?", subtree, deletedAt).Error
+		if err != nil {
+			return err
+		}
+
+		err = audit.trackWhere("deleted_at = ? AND category_id IN (SELECT id FROM product_category WHERE path LIKE ?)", deletedAt, subtree)
+		if err != nil {
+			return err
+		}
+
+		return tx.Raw("UPDATE product SET deleted_at = NULL, version = version + 1 WHERE deleted_at = ? AND category_id IN (SELECT id FROM product_category WHERE path LIKE ?) RETURNING id", deletedAt, subtree).Scan(&productIDs).Error
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	productCategory, err := r.FindProductCategoryByID(ctx, productCategoryID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return productCategory, productIDs, nil
+}
+
+// FindCategorySubtree returns the live category and its live descendants, parents before children
+func (r *ProductRepository) FindCategorySubtree(ctx context.Context, productCategoryID int64) ([]models.ProductCategory, error) {
+	var productCategories []models.ProductCategory
+	err := r.Database.WithContext(ctx).Table("product_category").
+		Where("path LIKE (SELECT path FROM product_category WHERE id = ? AND deleted_at IS NULL) || '%'", productCategoryID).
+		Order("path").
+		Find(&productCategories).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(productCategories) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return productCategories, nil
+}
+
+// FindCategorySubtreeIDs includes soft deleted categories, callers use it to drop cached entries
+func (r *ProductRepository) FindCategorySubtreeIDs(ctx context.Context, productCategoryID int64) ([]int64, error) {
+	var productCategoryIDs []int64
+	err := r.Database.WithContext(ctx).
+		Raw("SELECT id FROM product_category WHERE path LIKE (SELECT path FROM product_category WHERE id = ?) || '%'", productCategoryID).
+		Scan(&productCategoryIDs).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return productCategoryIDs, nil
+}
+
+// FindCategoryBreadcrumbs returns the ancestors of a category and the category itself, root first
+func (r *ProductRepository) FindCategoryBreadcrumbs(ctx context.Context, productCategoryID int64) (*[]models.CategoryBreadcrumb, error) {
+	var breadcrumbs []models.CategoryBreadcrumb
+	err := r.Database.WithContext(ctx).
+		Raw(`SELECT ancestor.id, ancestor.name FROM product_category category
+			JOIN product_category ancestor ON category.path LIKE ancestor.path || '%'
+			WHERE category.id = ? ORDER BY length(ancestor.path)`, productCategoryID).
+		Scan(&breadcrumbs).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(breadcrumbs) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &breadcrumbs, nil
+}
+
+// lockCategory reads a live category with a row lock, strength is UPDATE or SHARE
+func lockCategory(tx *gorm.DB, productCategoryID int64, strength string) (*models.ProductCategory, error) {
+	var productCategory models.ProductCategory
+	err := tx.Table("product_category").Clauses(clause.Locking{Strength: strength}).Where("id = ?", productCategoryID).Take(&productCategory).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &productCategory, nil
+}
+
+// moveCategorySubtree rewrites the path prefix of category and every descendant for the new parent,
+// a parent inside the subtree would close a cycle and is rejected
+func moveCategorySubtree(tx *gorm.DB, category *models.ProductCategory, parentID *int64) error {
+	parentPath := "/"
+
+	if parentID != nil {
+		parent, err := lockCategory(tx, *parentID, "SHARE")
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(parent.Path, category.Path) {
+			return models.ErrCategoryCycle
+		}
+
+		parentPath = parent.Path
+	}
+
+	newPath := fmt.Sprintf("%s%d/", parentPath, category.ID)
+
+	return tx.Exec("UPDATE product_category SET path = ? || substr(path, ?) WHERE path LIKE ?", newPath, len(category.Path)+1, category.Path+"%").Error
+}
+
+func sameParent(a *int64, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
@@ -2,8 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"product/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func (r *ProductRepository) FindProductByID(ctx context.Context, productID int64) (*models.Product, error) {
@@ -14,9 +20,67 @@ func (r *ProductRepository) FindProductByID(ctx context.Context, productID int64
 		return nil, err
 	}
 
+	err = r.attachDetails(r.Database.WithContext(ctx), &product)
+	if err != nil {
+		return nil, err
+	}
+
 	return &product, nil
 }
 
+// FindProductByIDWithDeleted also returns a soft deleted product
+func (r *ProductRepository) FindProductByIDWithDeleted(ctx context.Context, productID int64) (*models.Product, error) {
+	var product models.Product
+	err := r.Database.WithContext(ctx).Unscoped().Table("product").Where("id = ?", productID).Last(&product).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.attachDetails(r.Database.WithContext(ctx), &product)
+	if err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+func (r *ProductRepository) FindProductsByIDs(ctx context.Context, productIDs []int64) ([]models.Product, error) {
+	var products []models.Product
+	err := r.Database.WithContext(ctx).Table("product").Where("id IN ?", productIDs).Find(&products).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	pointers := make([]*models.Product, len(products))
+	for i := range products {
+		pointers[i] = &products[i]
+	}
+
+	err = r.attachDetails(r.Database.WithContext(ctx), pointers...)
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// attachDetails fills the variants, price lists and sales of the products looked up by id, db may be a transaction
+func (r *ProductRepository) attachDetails(db *gorm.DB, products ...*models.Product) error {
+	err := r.attachVariants(db, products...)
+	if err != nil {
+		return err
+	}
+
+	err = r.attachPrices(db, products...)
+	if err != nil {
+		return err
+	}
+
+	return r.attachPriceSchedules(db, products...)
+}
+
 func (r *ProductRepository) FindProductCategoryByID(ctx context.Context, productCategoryID int64) (*models.ProductCategory, error) {
 	var productCategory models.ProductCategory
 	err := r.Database.WithContext(ctx).Table("product_category").Where("id = ?", productCategoryID).Last(&productCategory).Error
@@ -29,7 +93,17 @@ func (r *ProductRepository) FindProductCategoryByID(ctx context.Context, product
 }
 
 func (r *ProductRepository) InsertNewProduct(ctx context.Context, product *models.Product) (int64, error) {
-	err := r.Database.WithContext(ctx).Table("product").Create(product).Error
+	product.Version = 1
+	err := r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		err := tx.Table("product").Create(product).Error
+		if err != nil {
+			return err
+		}
+
+		audit.created(product.ID)
+
+		return nil
+	})
 
 	if err != nil {
 		return 0, err
@@ -38,54 +112,336 @@ func (r *ProductRepository) InsertNewProduct(ctx context.Context, product *model
 	return product.ID, nil
 }
 
-func (r *ProductRepository) InsertNewProductCategory(ctx context.Context, productCategory *models.ProductCategory) (int64, error) {
-	err := r.Database.WithContext(ctx).Table("product_category").Create(productCategory).Error
+// FindProductCategoryByIDWithDeleted also returns a soft deleted category
+func (r *ProductRepository) FindProductCategoryByIDWithDeleted(ctx context.Context, productCategoryID int64) (*models.ProductCategory, error) {
+	var productCategory models.ProductCategory
+	err := r.Database.WithContext(ctx).Unscoped().Table("product_category").Where("id = ?", productCategoryID).Last(&productCategory).Error
 
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	return productCategory.ID, nil
+	return &productCategory, nil
+}
+
+// FindProductCategoriesByNames includes soft deleted categories, their names stay taken by the unique constraint
+func (r *ProductRepository) FindProductCategoriesByNames(ctx context.Context, names []string) ([]models.ProductCategory, error) {
+	var productCategories []models.ProductCategory
+	err := r.Database.WithContext(ctx).Unscoped().Table("product_category").Where("name IN ?", names).Find(&productCategories).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return productCategories, nil
 }
 
+// InsertProductCategoriesByNames creates the missing categories and returns every named one, soft deleted included,
+// together with the names it inserted. names taken concurrently by someone else are picked up instead of failing
+func (r *ProductRepository) InsertProductCategoriesByNames(ctx context.Context, names []string) ([]models.ProductCategory, []string, error) {
+	var created []string
+
+	err := r.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// one insert per name, a batch with DO NOTHING returns fewer ids than rows and cannot tell which were taken
+		for _, name := range names {
+			productCategory := models.ProductCategory{Name: name, Version: 1}
+
+			result := tx.Table("product_category").
+				Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).
+				Create(&productCategory)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			if result.RowsAffected > 0 {
+				created = append(created, name)
+			}
+		}
+
+		if len(created) == 0 {
+			return nil
+		}
+
+		// imported categories are roots
+		return tx.Exec("UPDATE product_category SET path = '/' || id || '/' WHERE name IN ? AND path = ''", created).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	productCategories, err := r.FindProductCategoriesByNames(ctx, names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return productCategories, created, nil
+}
+
+// FindProductsByNames includes soft deleted products, their names stay taken by the unique constraint
+func (r *ProductRepository) FindProductsByNames(ctx context.Context, names []string) ([]models.Product, error) {
+	var products []models.Product
+	err := r.Database.WithContext(ctx).Unscoped().Table("product").Where("name IN ?", names).Find(&products).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// UpsertProducts writes the batch in one transaction with INSERT ... ON CONFLICT (name) DO UPDATE.
+// ids and versions are filled in place, the returned slice tells which rows were inserted.
+// stock overwritten on an existing product is recorded as an import movement, a soft deleted product is restored
+func (r *ProductRepository) UpsertProducts(ctx context.Context, products []models.Product) ([]bool, error) {
+	names := make([]string, len(products))
+	for i := range products {
+		names[i] = products[i].Name
+	}
+
+	created := make([]bool, len(products))
+
+	err := r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		// lock the rows about to be overwritten so the stock movements see the stock they replace
+		var existing []models.Product
+		err := tx.Unscoped().Table("product").Where("name IN ?", names).Clauses(clause.Locking{Strength: "UPDATE"}).Find(&existing).Error
+		if err != nil {
+			return err
+		}
+
+		existingByName := make(map[string]models.Product, len(existing))
+		existingIDs := make([]int64, len(existing))
+		for i, product := range existing {
+			existingByName[product.Name] = product
+			existingIDs[i] = product.ID
+		}
+
+		err = audit.track(existingIDs...)
+		if err != nil {
+			return err
+		}
+
+		for i := range products {
+			products[i].Version = 1
+		}
+
+		err = tx.Table("product").
+			Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "name"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"description": gorm.Expr("excluded.description"),
+					"price":       gorm.Expr("excluded.price"),
+					"currency":    gorm.Expr("excluded.currency"),
+					"stock":       gorm.Expr("excluded.stock"),
+					"category_id": gorm.Expr("excluded.category_id"),
+					"version":     gorm.Expr("product.version + 1"),
+					"deleted_at":  nil,
+				}),
+			}).
+			Create(&products).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		var movements []models.InventoryMovement
+		for i := range products {
+			previous, ok := existingByName[products[i].Name]
+			if !ok {
+				created[i] = true
+				audit.created(products[i].ID)
+
+				continue
+			}
+
+			products[i].ID = previous.ID
+			products[i].Version = previous.Version + 1
+
+			if delta := products[i].Stock - previous.Stock; delta != 0 {
+				movements = append(movements, models.InventoryMovement{
+					ProductID:  previous.ID,
+					Delta:      delta,
+					StockAfter: products[i].Stock,
+					Reason:     models.MovementReasonImport,
+					CreatedAt:  now,
+				})
+			}
+		}
+
+		if len(movements) == 0 {
+			return nil
+		}
+
+		return tx.Table("inventory_movement").Create(&movements).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// update with optimistic locking, a non zero product.Version must match the stored row.
+// a new stock is written through the ledger as an edit movement
 func (r *ProductRepository) UpdateProduct(ctx context.Context, product *models.Product) (*models.Product, error) {
-	err := r.Database.WithContext(ctx).Table("product").Save(product).Error
+	err := r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		err := audit.track(product.ID)
+		if err != nil {
+			return err
+		}
+
+		err = r.updateVersioned(tx, "product", product.ID, product.Version, map[string]interface{}{
+			"name":        product.Name,
+			"description": product.Description,
+			"price":       product.Price,
+			"currency":    product.Currency,
+			"category_id": product.CategoryID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return r.setStock(tx, product.ID, product.Stock, models.MovementReasonEdit)
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return product, nil
+	return r.FindProductByID(ctx, product.ID)
 }
 
-func (r *ProductRepository) UpdateProductCategory(ctx context.Context, productCategory *models.ProductCategory) (*models.ProductCategory, error) {
-	err := r.Database.WithContext(ctx).Table("product_category").Save(productCategory).Error
+// patch only writes the given columns, same optimistic locking rules as UpdateProduct
+func (r *ProductRepository) PatchProduct(ctx context.Context, productID int64, version int64, values map[string]interface{}) (*models.Product, error) {
+	err := r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		err := audit.track(productID)
+		if err != nil {
+			return err
+		}
+
+		// stock goes through the ledger, written after the version check
+		stock, hasStock := values["stock"].(int)
+		delete(values, "stock")
+
+		err = r.updateVersioned(tx, "product", productID, version, values)
+		if err != nil || !hasStock {
+			return err
+		}
+
+		return r.setStock(tx, productID, stock, models.MovementReasonEdit)
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return productCategory, nil
+	return r.FindProductByID(ctx, productID)
 }
 
-func (r *ProductRepository) DeleteProduct(ctx context.Context, productID int64) error {
-	err := r.Database.WithContext(ctx).Table("product").Delete(&models.Product{}, productID).Error
+func (r *ProductRepository) ExistsProductName(ctx context.Context, name string, excludeProductID int64) (bool, error) {
+	var count int64
+	err := r.Database.WithContext(ctx).Table("product").Where("name = ? AND id <> ?", name, excludeProductID).Count(&count).Error
 
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	return count > 0, nil
 }
 
-func (r *ProductRepository) DeleteProductCategory(ctx context.Context, productCategoryID int64) error {
-	err := r.Database.WithContext(ctx).Table("product_category").Delete(&models.ProductCategory{}, productCategoryID).Error
+// updateVersioned never touches a soft deleted row, it is reported as not found
+func (r *ProductRepository) updateVersioned(db *gorm.DB, table string, id int64, version int64, values map[string]interface{}) error {
+	query := db.Table(table).Where("id = ? AND deleted_at IS NULL", id)
 
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	}
+
+	values["version"] = gorm.Expr("version + 1")
+
+	result := query.Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int64
+	err := db.Table(table).Where("id = ? AND deleted_at IS NULL", id).Count(&count).Error
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return models.ErrVersionConflict
+}
+
+// DeleteProduct soft deletes, the row keeps its name and stock and can be restored
+func (r *ProductRepository) DeleteProduct(ctx context.Context, productID int64) error {
+	return r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		err := audit.track(productID)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Table("product").Delete(&models.Product{}, productID)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+// RestoreProduct clears deleted_at, restoring a live product is a no-op.
+// a product whose category is deleted stays deleted until the category is back
+func (r *ProductRepository) RestoreProduct(ctx context.Context, productID int64) (*models.Product, error) {
+	err := r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		err := audit.track(productID)
+		if err != nil {
+			return err
+		}
+
+		var product models.Product
+		err = tx.Unscoped().Table("product").Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", productID).Take(&product).Error
+		if err != nil {
+			return err
+		}
+
+		if !product.DeletedAt.Valid {
+			return nil
+		}
+
+		var count int64
+		err = tx.Table("product_category").Where("id = ? AND deleted_at IS NULL", product.CategoryID).Count(&count).Error
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return models.ErrCategoryDeleted
+		}
+
+		return tx.Unscoped().Table("product").Where("id = ?", productID).Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		}).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindProductByID(ctx, productID)
 }
 
 // search product
@@ -93,47 +449,397 @@ func (r *ProductRepository) SearchProduct(ctx context.Context, param *models.Sea
 	var products []models.Product
 	var totalCount int64
 
-	query := r.Database.WithContext(ctx).Table("product").Select("product.id, product.name, product.description, product.price, product.stock, product.category_id, product_category.name AS category").Joins("JOIN product_category ON product_category.id = product.category_id")
+	hasSearch := param.Name != ""
+	columns := "product.id, product.name, product.description, product.price, product.list_price, product.sale_price, product.sale_ends_at, product.currency, product.stock, product.category_id, product.version, product.deleted_at, product_category.name AS category"
+	if hasSearch {
+		columns += ", " + relevanceExpression + " AS relevance, " + snippetExpression + " AS snippet"
+	}
+
+	query := r.searchProductQuery(ctx, param).Select(columns)
 
-	// filter
-	if param.Name != "" {
-		query = query.Where("product.name ILIKE ?", "%"+param.Name+"%")
+	// order by, validated before any query runs
+	orderBy, err := productOrderBy(param.OrderBy, param.Sort, hasSearch)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	if param.Category != "" {
-		query = query.Where("product_category.name = ?", param.Category)
+	// total count is optional, deep keyset pages should not pay for a COUNT(*)
+	if param.IncludeTotal {
+		err = query.Session(&gorm.Session{}).Count(&totalCount).Error
+		if err != nil {
+			return nil, 0, err
+		}
 	}
 
-	if param.MinPrice > 0 {
-		query = query.Where("product.price >= ?", param.MinPrice)
+	// pagination, one extra row tells the caller whether another page exists
+	if param.Keyset != nil {
+		condition, args := keysetCondition(orderBy, param.Keyset)
+		query = query.Where(condition, args...)
+
+		if param.Keyset.Backward {
+			orderBy = reverseOrderBy(orderBy)
+		}
+	} else {
+		query = query.Offset((param.Page - 1) * param.PageSize)
 	}
 
-	if param.MaxPrice > 0 {
-		query = query.Where("product.price <= ?", param.MaxPrice)
+	query = query.Order(clause.OrderBy{Columns: orderBy}).Limit(param.PageSize + 1)
+
+	err = query.Scan(&products).Error
+	if err != nil {
+		return nil, 0, err
 	}
 
-	// pagination
-	query.Model(&models.Product{}).Count(&totalCount)
+	return products, int(totalCount), nil
+}
+
+// facet counts for every requested facet over the same filters as SearchProduct, in one query.
+// price and stock read variants the way the filters do: a product is in stock when it or one of its variants is,
+// and it counts in every price bucket one of its variants falls in, so the buckets may add up to more than the total
+func (r *ProductRepository) SearchProductFacets(ctx context.Context, param *models.SearchProductParameter) ([]models.FacetRow, error) {
+	var rows []models.FacetRow
 
-	// default order by
-	if param.OrderBy == "" {
-		param.OrderBy = "product.name"
+	inStock := fmt.Sprintf("((NOT %s AND product.stock > 0) OR %s) AS in_stock", hasVariants, fmt.Sprintf(variantMatching, "product_variant.stock > 0"))
+
+	filtered := r.searchProductQuery(ctx, param).
+		Select("product.id, product.price, product.base_currency, " + inStock + ", product.category_id, product_category.name AS category")
+
+	price := variantPrice
+	if param.Currency != "" {
+		price = variantPriceInCurrency
 	}
 
-	if param.Sort == "" || (param.Sort != "ASC" && param.Sort != "DESC") {
-		param.Sort = "ASC"
+	var parts []string
+	args := []interface{}{filtered}
+
+	for _, facet := range param.Facets {
+		switch facet {
+		case models.FacetCategory:
+			parts = append(parts, "SELECT 'category' AS facet, category_id::bigint AS key, category::text AS label, count(*) AS count FROM filtered GROUP BY category_id, category")
+		case models.FacetPrice:
+			// a product without variants joins no variant and is bucketed at its own price
+			parts = append(parts, fmt.Sprintf("SELECT 'price', width_bucket(%s, ?::numeric[])::bigint, ''::text, count(DISTINCT product.id) "+
+				"FROM filtered AS product LEFT JOIN product_variant ON product_variant.product_id = product.id GROUP BY 2", price))
+			args = append(args, numericArray(param.PriceBuckets))
+		case models.FacetStock:
+			parts = append(parts, "SELECT 'stock', CASE WHEN in_stock THEN 1 ELSE 0 END::bigint, ''::text, count(*) FROM filtered GROUP BY 2")
+		}
 	}
 
-	orderBy := fmt.Sprintf("%s %s", param.OrderBy, param.Sort)
-	query = query.Order(orderBy)
+	if len(parts) == 0 {
+		return nil, nil
+	}
 
-	offset := (param.Page - 1) * param.PageSize
-	query = query.Limit(param.PageSize).Offset(offset)
+	err := r.Database.WithContext(ctx).Raw("WITH filtered AS (?) "+strings.Join(parts, " UNION ALL "), args...).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
 
-	err := query.Scan(&products).Error
+	return rows, nil
+}
+
+// StreamProducts reads the filtered products through an open cursor and hands them to fn one by one,
+// only the current row is held in memory. an error from fn stops the scan and is returned as is.
+// the service asks for list prices so an export can be imported back without making a sale permanent,
+// and min_price and max_price filter on the price that is exported. price and list_price are then the same
+func (r *ProductRepository) StreamProducts(ctx context.Context, param *models.SearchProductParameter, fn func(*models.Product) error) error {
+	rows, err := r.searchProductQuery(ctx, param).
+		Select("product.id, product.name, product.description, product.price, product.list_price, product.currency, product.stock, product.category_id, product.version, product.deleted_at, product_category.name AS category").
+		Order("product.id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product models.Product
+
+		err = r.Database.ScanRows(rows, &product)
+		if err != nil {
+			return err
+		}
+
+		err = fn(&product)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// numericArray formats bounds as a postgres array literal, a plain slice would be expanded into a row
+func numericArray(values []models.Decimal) string {
+	bounds := make([]string, len(values))
+	for i, value := range values {
+		bounds[i] = value.String()
+	}
+
+	return "{" + strings.Join(bounds, ",") + "}"
+}
+
+// searchProductQuery joins the category and applies every search filter, callers pick the columns.
+// the query is unscoped because raw selects carry no model, filterByDeleted handles soft deletes for all of them.
+// the product relation carries resolved prices, see pricedProducts
+func (r *ProductRepository) searchProductQuery(ctx context.Context, param *models.SearchProductParameter) *gorm.DB {
+	query := r.Database.WithContext(ctx).Unscoped().Table("(?) AS product", r.pricedProducts(param)).
+		Joins("JOIN product_category ON product_category.id = product.category_id")
+
+	for _, filter := range productSearchFilters {
+		query = filter(query, param)
+	}
+
+	return query
+}
+
+// stock reservation
+func (r *ProductRepository) ReserveStock(ctx context.Context, reservation *models.StockReservation) error {
+	return r.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := r.ensureProductLive(tx, reservation.ProductID)
+		if err != nil {
+			return err
+		}
+
+		_, err = r.applyStockDelta(tx, reservation.ProductID, -reservation.Quantity, models.MovementReasonReserved, reservation.ID, "")
+		if err != nil {
+			return err
+		}
+
+		return tx.Table("stock_reservation").Create(reservation).Error
+	})
+}
+
+func (r *ProductRepository) CommitReservation(ctx context.Context, reservationID string, now time.Time) (*models.StockReservation, error) {
+	var reservation models.StockReservation
+
+	err := r.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("stock_reservation").Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", reservationID).Take(&reservation).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return models.ErrReservationNotFound
+			}
+
+			return err
+		}
+
+		if reservation.Status != models.ReservationStatusReserved || !reservation.ExpiresAt.After(now) {
+			return models.ErrReservationNotActive
+		}
+
+		reservation.Status = models.ReservationStatusCommitted
+		reservation.UpdatedAt = now
+
+		return tx.Table("stock_reservation").Where("id = ?", reservationID).Updates(map[string]interface{}{
+			"status":     reservation.Status,
+			"updated_at": reservation.UpdatedAt,
+		}).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+func (r *ProductRepository) ReleaseReservation(ctx context.Context, reservationID string, now time.Time) (*models.StockReservation, error) {
+	var reservation models.StockReservation
+
+	err := r.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("stock_reservation").Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", reservationID).Take(&reservation).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return models.ErrReservationNotFound
+			}
+
+			return err
+		}
+
+		if reservation.Status != models.ReservationStatusReserved {
+			return models.ErrReservationNotActive
+		}
+
+		return r.restoreReservedStock(tx, &reservation, models.ReservationStatusReleased, models.MovementReasonReleased, now)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+// expire reservations past their deadline and give the units back, SKIP LOCKED lets several sweepers run side by side
+func (r *ProductRepository) ExpireReservations(ctx context.Context, now time.Time, limit int) ([]models.StockReservation, error) {
+	var reservations []models.StockReservation
+
+	err := r.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("stock_reservation").Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).Where("status = ? AND expires_at <= ?", models.ReservationStatusReserved, now).Limit(limit).Find(&reservations).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range reservations {
+			if err := r.restoreReservedStock(tx, &reservations[i], models.ReservationStatusExpired, models.MovementReasonExpired, now); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
+
+func (r *ProductRepository) restoreReservedStock(tx *gorm.DB, reservation *models.StockReservation, status string, reason string, now time.Time) error {
+	_, err := r.applyStockDelta(tx, reservation.ProductID, reservation.Quantity, reason, reservation.ID, "")
+	if err != nil {
+		return err
+	}
+
+	reservation.Status = status
+	reservation.UpdatedAt = now
+
+	return tx.Table("stock_reservation").Where("id = ?", reservation.ID).Updates(map[string]interface{}{
+		"status":     reservation.Status,
+		"updated_at": reservation.UpdatedAt,
+	}).Error
+}
+
+// stock adjustment
+func (r *ProductRepository) AdjustStock(ctx context.Context, productID int64, delta int, reason string, note string) (*models.InventoryMovement, error) {
+	var movement *models.InventoryMovement
+
+	err := r.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := r.ensureProductLive(tx, productID)
+		if err != nil {
+			return err
+		}
+
+		movement, err = r.applyStockDelta(tx, productID, delta, reason, "", note)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return movement, nil
+}
+
+func (r *ProductRepository) FindInventoryMovements(ctx context.Context, productID int64, page int, pageSize int) ([]models.InventoryMovement, int, error) {
+	var movements []models.InventoryMovement
+	var totalCount int64
+
+	query := r.Database.WithContext(ctx).Table("inventory_movement").Where("product_id = ?", productID)
+
+	err := query.Count(&totalCount).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
-	return products, int(totalCount), nil
+	offset := (page - 1) * pageSize
+	err = query.Order("id DESC").Limit(pageSize).Offset(offset).Find(&movements).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return movements, int(totalCount), nil
+}
+
+// ensureProductLive rejects soft deleted products for new stock changes,
+// releasing and expiring reservations still give units back to them so a restore finds the right stock
+func (r *ProductRepository) ensureProductLive(tx *gorm.DB, productID int64) error {
+	var count int64
+	err := tx.Table("product").Where("id = ? AND deleted_at IS NULL", productID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// setStock brings the stock of a product to an absolute value, the difference lands in the ledger under reason.
+// it runs inside a versioned update that already bumped the version, so the stock change does not bump it again
+func (r *ProductRepository) setStock(tx *gorm.DB, productID int64, stock int, reason string) error {
+	var current []int
+	err := tx.Raw("SELECT stock FROM product WHERE id = ? FOR UPDATE", productID).Scan(&current).Error
+	if err != nil {
+		return err
+	}
+
+	if len(current) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	if stock == current[0] {
+		return nil
+	}
+
+	_, err = r.moveStock(tx, productID, stock-current[0], false, reason, "", "")
+
+	return err
+}
+
+// applyStockDelta is the only place product.stock changes by a delta on its own, every change lands in the ledger within tx.
+// the version moves with the stock so an edit holding an older ETag cannot overwrite this change
+func (r *ProductRepository) applyStockDelta(tx *gorm.DB, productID int64, delta int, reason string, referenceID string, note string) (*models.InventoryMovement, error) {
+	return r.moveStock(tx, productID, delta, true, reason, referenceID, note)
+}
+
+// moveStock changes the stock by delta and records the movement, bumpVersion is false when the caller bumped it already
+func (r *ProductRepository) moveStock(tx *gorm.DB, productID int64, delta int, bumpVersion bool, reason string, referenceID string, note string) (*models.InventoryMovement, error) {
+	var stockAfter []int
+
+	query := "UPDATE product SET stock = stock + ? WHERE id = ? AND stock + ? >= 0 RETURNING stock"
+	if bumpVersion {
+		query = "UPDATE product SET stock = stock + ?, version = version + 1 WHERE id = ? AND stock + ? >= 0 RETURNING stock"
+	}
+
+	// conditional update, the row lock taken by UPDATE serializes concurrent writers
+	err := tx.Raw(query, delta, productID, delta).Scan(&stockAfter).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stockAfter) == 0 {
+		var count int64
+		err := tx.Table("product").Where("id = ?", productID).Count(&count).Error
+		if err != nil {
+			return nil, err
+		}
+
+		if count == 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+
+		return nil, models.ErrInsufficientStock
+	}
+
+	movement := &models.InventoryMovement{
+		ProductID:   productID,
+		Delta:       delta,
+		StockAfter:  stockAfter[0],
+		Reason:      reason,
+		ReferenceID: referenceID,
+		Note:        note,
+		CreatedAt:   time.Now(),
+	}
+
+	err = tx.Table("inventory_movement").Create(movement).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return movement, nil
 }
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"fmt"
+	"product/models"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// productFilter narrows the search query for one parameter and leaves it untouched when that parameter is unset
+type productFilter func(query *gorm.DB, param *models.SearchProductParameter) *gorm.DB
+
+// every search filter, a new one only needs to be appended here
+var productSearchFilters = []productFilter{
+	filterByName,
+	filterByCategory,
+	filterByCategoryIDs,
+	filterByPriceAndStock,
+	filterByIDs,
+	filterByExcludedIDs,
+	filterByDeleted,
+}
+
+// full text search over name and description, trigram similarity on name catches typos
+func filterByName(query *gorm.DB, param *models.SearchProductParameter) *gorm.DB {
+	if param.Name == "" {
+		return query
+	}
+
+	return query.Joins("CROSS JOIN (SELECT websearch_to_tsquery('simple', ?) AS query, ?::text AS term) AS search", param.Name, param.Name).
+		Where("(product.search_vector @@ search.query OR product.name % search.term)")
+}
+
+// category filters match the named categories and every category below them
+const categorySubtreeCondition = `product.category_id IN (
+	SELECT descendant.id FROM product_category selected
+	JOIN product_category descendant ON descendant.path LIKE selected.path || '%%'
+	WHERE %s)`
+
+func filterByCategory(query *gorm.DB, param *models.SearchProductParameter) *gorm.DB {
+	if param.Category == "" {
+		return query
+	}
+
+	if id, err := strconv.ParseInt(param.Category, 10, 64); err == nil {
+		return query.Where(fmt.Sprintf(categorySubtreeCondition, "selected.name = ? OR selected.id = ?"), param.Category, id)
+	}
+
+	return query.Where(fmt.Sprintf(categorySubtreeCondition, "selected.name = ?"), param.Category)
+}
+
+func filterByCategoryIDs(query *gorm.DB, param *models.SearchProductParameter) *gorm.DB {
+	if len(param.CategoryIDs) == 0 {
+		return query
+	}
+
+	return query.Where(fmt.Sprintf(categorySubtreeCondition, "selected.id IN ?"), param.CategoryIDs)
+}
+
+// a product with variants is sold through them, price and stock filters match when one variant satisfies
+// all of them and a variant without its own price counts at the product price.
+// a product without variants is matched on its own price and stock
+const (
+	hasVariants     = "EXISTS (SELECT 1 FROM product_variant WHERE product_variant.product_id = product.id)"
+	variantMatching = "EXISTS (SELECT 1 FROM product_variant WHERE product_variant.product_id = product.id AND %s)"
+)
+
+// variant price overrides are in the product's own currency, a price list entry prices every variant
+const (
+	variantPrice           = "COALESCE(product_variant.price, product.price)"
+	variantPriceInCurrency = "COALESCE(CASE WHEN product.base_currency THEN product_variant.price END, product.price)"
+)
+
+func filterByPriceAndStock(query *gorm.DB, param *models.SearchProductParameter) *gorm.DB {
+	var productConditions, variantConditions []string
+	var args []interface{}
+
+	price := variantPrice
+	if param.Currency != "" {
+		price = variantPriceInCurrency
+	}
+
+	if param.MinPrice != nil {
+		productConditions = append(productConditions, "product.price >= ?")
+		variantConditions = append(variantConditions, price+" >= ?")
+		args = append(args, *param.MinPrice)
+	}
+
+	if param.MaxPrice != nil {
+		productConditions = append(productConditions, "product.price <= ?")
+		variantConditions = append(variantConditions, price+" <= ?")
+		args = append(args, *param.MaxPrice)
+	}
+
+	if param.InStock != nil && *param.InStock {
+		productConditions = append(productConditions, "product.stock > 0")
+		variantConditions = append(variantConditions, "product_variant.stock > 0")
+	}
+
+	if len(productConditions) > 0 {
+		condition := fmt.Sprintf("((NOT %s AND %s) OR %s)", hasVariants, strings.Join(productConditions, " AND "),
+			fmt.Sprintf(variantMatching, strings.Join(variantConditions, " AND ")))
+
+		query = query.Where(condition, append(args, args...)...)
+	}
+
+	// out of stock means nothing can be bought, neither the product itself nor any of its variants
+	if param.InStock != nil && !*param.InStock {
+		query = query.Where(fmt.Sprintf("((NOT %s AND product.stock = 0) OR (%s AND NOT %s))",
+			hasVariants, hasVariants, fmt.Sprintf(variantMatching, "product_variant.stock > 0")))
+	}
+
+	return query
+}
+
+func filterByIDs(query *gorm.DB, param *models.SearchProductParameter) *gorm.DB {
+	if len(param.IDs) == 0 {
+		return query
+	}
+
+	return query.Where("product.id IN ?", param.IDs)
+}
+
+func filterByExcludedIDs(query *gorm.DB, param *models.SearchProductParameter) *gorm.DB {
+	if len(param.ExcludeIDs) == 0 {
+		return query
+	}
+
+	return query.Where("product.id NOT IN ?", param.ExcludeIDs)
+}
+
+func filterByDeleted(query *gorm.DB, param *models.SearchProductParameter) *gorm.DB {
+	if param.IncludeDeleted {
+		return query
+	}
+
+	return query.Where("product.deleted_at IS NULL AND product_category.deleted_at IS NULL")
+}
@@ -0,0 +1,332 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"product/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// every write to a product, its variants, price list or sales runs through withHistory so the change and
+// its history entry commit together. stock moved by reservations and adjustments is left to the inventory
+// ledger, FindProductAsOf reads it from there
+
+// productAudit collects the products a transaction changes together with their snapshot from before
+type productAudit struct {
+	repository *ProductRepository
+	tx         *gorm.DB
+	before     map[int64]*models.Product
+	productIDs []int64
+}
+
+// track snapshots products about to change and locks their rows so concurrent changes queue up behind this one.
+// a product tracked again keeps its first snapshot, one that does not exist has none
+func (a *productAudit) track(productIDs ...int64) error {
+	var pending []int64
+	for _, productID := range productIDs {
+		if _, ok := a.before[productID]; !ok {
+			pending = append(pending, productID)
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	snapshots, err := a.repository.snapshotProducts(a.tx, pending, true)
+	if err != nil {
+		return err
+	}
+
+	for _, productID := range pending {
+		a.before[productID] = snapshots[productID]
+		a.productIDs = append(a.productIDs, productID)
+	}
+
+	return nil
+}
+
+// trackWhere tracks the products matching a condition on the product table, soft deleted ones included
+func (a *productAudit) trackWhere(query string, args ...interface{}) error {
+	var productIDs []int64
+	err := a.tx.Unscoped().Table("product").Where(query, args...).Pluck("id", &productIDs).Error
+	if err != nil {
+		return err
+	}
+
+	return a.track(productIDs...)
+}
+
+// created tracks products inserted by the transaction
+func (a *productAudit) created(productIDs ...int64) {
+	for _, productID := range productIDs {
+		if _, ok := a.before[productID]; !ok {
+			a.before[productID] = nil
+			a.productIDs = append(a.productIDs, productID)
+		}
+	}
+}
+
+// record writes an entry for every tracked product that changed, actor and request id come from ctx
+func (a *productAudit) record(ctx context.Context) error {
+	if len(a.productIDs) == 0 {
+		return nil
+	}
+
+	after, err := a.repository.snapshotProducts(a.tx, a.productIDs, false)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	actor := models.ActorFromContext(ctx)
+	requestID, _ := ctx.Value("request_id").(string)
+
+	var entries []models.ProductHistory
+	for _, productID := range a.productIDs {
+		before, current := a.before[productID], after[productID]
+		if current == nil {
+			continue
+		}
+
+		changes, err := changedFields(before, current)
+		if err != nil {
+			return err
+		}
+
+		if before != nil && len(changes) == 0 {
+			continue
+		}
+
+		entries = append(entries, models.ProductHistory{
+			ProductID: productID,
+			Action:    historyAction(before, current),
+			Changes:   changes,
+			Actor:     actor,
+			RequestID: requestID,
+			Before:    before,
+			After:     current,
+			ChangedAt: now,
+		})
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	return a.tx.Table("product_history").Create(&entries).Error
+}
+
+// withHistory runs change in a transaction and records the products it tracked in the product history
+func (r *ProductRepository) withHistory(ctx context.Context, change func(tx *gorm.DB, audit *productAudit) error) error {
+	return r.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		audit := &productAudit{repository: r, tx: tx, before: map[int64]*models.Product{}}
+
+		err := change(tx, audit)
+		if err != nil {
+			return err
+		}
+
+		return audit.record(ctx)
+	})
+}
+
+// snapshotProducts reads the products with their details inside tx, soft deleted ones included
+func (r *ProductRepository) snapshotProducts(tx *gorm.DB, productIDs []int64, lock bool) (map[int64]*models.Product, error) {
+	query := tx.Unscoped().Table("product").Where("id IN ?", productIDs).Order("id")
+	if lock {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+
+	var products []models.Product
+	err := query.Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make(map[int64]*models.Product, len(products))
+	pointers := make([]*models.Product, len(products))
+	for i := range products {
+		pointers[i] = &products[i]
+		snapshots[products[i].ID] = &products[i]
+	}
+
+	err = r.attachDetails(tx, pointers...)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+// changedFields lists the json fields of the product that differ, every field of a new product
+func changedFields(before *models.Product, after *models.Product) ([]string, error) {
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	beforeFields := map[string]json.RawMessage{}
+	if before != nil {
+		beforeFields, err = jsonFields(before)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	changes := []string{}
+	for field, value := range afterFields {
+		if !bytes.Equal(beforeFields[field], value) {
+			changes = append(changes, field)
+		}
+	}
+
+	for field := range beforeFields {
+		if _, ok := afterFields[field]; !ok {
+			changes = append(changes, field)
+		}
+	}
+
+	sort.Strings(changes)
+
+	return changes, nil
+}
+
+func jsonFields(product *models.Product) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(product)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+
+	return fields, err
+}
+
+func historyAction(before *models.Product, after *models.Product) string {
+	switch {
+	case before == nil:
+		return models.HistoryActionCreate
+	case !before.DeletedAt.Valid && after.DeletedAt.Valid:
+		return models.HistoryActionDelete
+	case before.DeletedAt.Valid && !after.DeletedAt.Valid:
+		return models.HistoryActionRestore
+	default:
+		return models.HistoryActionUpdate
+	}
+}
+
+// FindProductHistory lists the changes to a product, newest first
+func (r *ProductRepository) FindProductHistory(ctx context.Context, productID int64, page int, pageSize int) ([]models.ProductHistory, int, error) {
+	var history []models.ProductHistory
+	var totalCount int64
+
+	query := r.Database.WithContext(ctx).Table("product_history").Where("product_id = ?", productID)
+
+	err := query.Count(&totalCount).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err = query.Order("changed_at DESC, id DESC").Limit(pageSize).Offset(offset).Find(&history).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return history, int(totalCount), nil
+}
+
+// FindProductAsOf rebuilds the product as it was at asOf, soft deleted or not. the latest history entry up to asOf
+// holds it, before that the earliest later entry found it unchanged, and a product without any entry has not
+// changed since the history began. sales and stock are then brought to asOf from their own tables.
+// a product created after asOf is not found
+func (r *ProductRepository) FindProductAsOf(ctx context.Context, productID int64, asOf time.Time) (*models.Product, error) {
+	db := r.Database.WithContext(ctx)
+
+	var product *models.Product
+	var takenAt time.Time
+
+	var entries []models.ProductHistory
+	err := db.Table("product_history").Where("product_id = ? AND changed_at <= ?", productID, asOf).
+		Order("changed_at DESC, id DESC").Limit(1).Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) > 0 {
+		product, takenAt = entries[0].After, entries[0].ChangedAt
+	} else {
+		err = db.Table("product_history").Where("product_id = ? AND changed_at > ?", productID, asOf).
+			Order("changed_at, id").Limit(1).Find(&entries).Error
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case len(entries) == 0:
+			takenAt = time.Now()
+
+			product, err = r.FindProductByIDWithDeleted(ctx, productID)
+			if err != nil {
+				return nil, err
+			}
+		case entries[0].Before == nil:
+			return nil, gorm.ErrRecordNotFound
+		default:
+			product, takenAt = entries[0].Before, entries[0].ChangedAt
+		}
+	}
+
+	// a later snapshot only carries the sales that had not ended by then, sales are never edited in place
+	var schedules []models.PriceSchedule
+	err = db.Table("product_price_schedule").Where("product_id = ? AND starts_at <= ? AND ends_at > ?", productID, asOf, asOf).Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, schedule := range schedules {
+		if !containsSchedule(product.PriceSchedules, schedule.ID) {
+			product.PriceSchedules = append(product.PriceSchedules, schedule)
+		}
+	}
+
+	// every stock movement lands in the ledger, replay the last one since the snapshot or undo back to asOf
+	var movements []models.InventoryMovement
+	if takenAt.After(asOf) {
+		err = db.Table("inventory_movement").Where("product_id = ? AND created_at > ? AND created_at < ?", productID, asOf, takenAt).
+			Order("created_at, id").Limit(1).Find(&movements).Error
+	} else {
+		err = db.Table("inventory_movement").Where("product_id = ? AND created_at > ? AND created_at <= ?", productID, takenAt, asOf).
+			Order("created_at DESC, id DESC").Limit(1).Find(&movements).Error
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(movements) > 0 {
+		product.Stock = movements[0].StockAfter
+		if takenAt.After(asOf) {
+			product.Stock -= movements[0].Delta
+		}
+	}
+
+	return product, nil
+}
+
+func containsSchedule(schedules []models.PriceSchedule, scheduleID int64) bool {
+	for _, schedule := range schedules {
+		if schedule.ID == scheduleID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"product/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// price lists hold the price of a product in currencies other than its own, when both exist
+// the product's own price wins
+
+// SetProductPrice creates or replaces the price of a live product in one currency
+func (r *ProductRepository) SetProductPrice(ctx context.Context, price *models.ProductPrice) error {
+	return r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		err := audit.track(price.ProductID)
+		if err != nil {
+			return err
+		}
+
+		err = r.ensureProductLive(tx, price.ProductID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Table("product_price").
+			Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "product_id"}, {Name: "currency"}},
+				DoUpdates: clause.AssignmentColumns([]string{"price"}),
+			}).
+			Create(price).Error
+	})
+}
+
+func (r *ProductRepository) DeleteProductPrice(ctx context.Context, productID int64, currency string) error {
+	return r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		err := audit.track(productID)
+		if err != nil {
+			return err
+		}
+
+		err = r.ensureProductLive(tx, productID)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Table("product_price").Where("product_id = ? AND currency = ?", productID, currency).Delete(&models.ProductPrice{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+// attachPrices fills Prices of every product with a single query, ordered by currency
+func (r *ProductRepository) attachPrices(db *gorm.DB, products ...*models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	productIDs := make([]int64, len(products))
+	for i, product := range products {
+		productIDs[i] = product.ID
+	}
+
+	var prices []models.ProductPrice
+	err := db.Table("product_price").Where("product_id IN ?", productIDs).Order("product_id, currency").Find(&prices).Error
+	if err != nil {
+		return err
+	}
+
+	byProduct := make(map[int64][]models.ProductPrice, len(products))
+	for _, price := range prices {
+		byProduct[price.ProductID] = append(byProduct[price.ProductID], price)
+	}
+
+	for _, product := range products {
+		product.Prices = byProduct[product.ID]
+	}
+
+	return nil
+}
+
+// pricedProducts stands in for the product table in searches. price is what the product sells for at
+// param.PricedAt, the running sale if there is one and the list price otherwise.
+// with a currency, price and currency come from the product itself when it is in that currency and from its
+// price list entry otherwise, products priced in neither are left out. sales only run in the product's own
+// currency and base_currency tells whether variant price overrides still apply. with ListPrices no sale runs
+func (r *ProductRepository) pricedProducts(param *models.SearchProductParameter) *gorm.DB {
+	listPrice, currency, baseCurrency := "product.price", "product.currency", "true"
+	saleJoin := "LEFT JOIN product_price_schedule AS sale ON sale.product_id = product.id AND sale.starts_at <= ? AND sale.ends_at > ?"
+	saleArgs := []interface{}{param.PricedAt, param.PricedAt}
+	var selectArgs []interface{}
+
+	if param.ListPrices {
+		saleJoin += " AND false"
+	}
+
+	if param.Currency != "" {
+		listPrice = "CASE WHEN product.currency = ? THEN product.price ELSE product_price.price END"
+		currency = "?::varchar"
+		baseCurrency = "product.currency = ?"
+		saleJoin += " AND product.currency = ?"
+		saleArgs = append(saleArgs, param.Currency)
+		selectArgs = []interface{}{param.Currency, param.Currency, param.Currency, param.Currency}
+	}
+
+	query := r.Database.Table("product").
+		Select(fmt.Sprintf(`product.id, product.name, product.description, product.stock, product.category_id, product.version,
+			product.deleted_at, product.search_vector, %[2]s AS currency,
+			COALESCE(sale.sale_price, %[1]s) AS price, %[1]s AS list_price, sale.sale_price, sale.ends_at AS sale_ends_at,
+			%[3]s AS base_currency`, listPrice, currency, baseCurrency), selectArgs...).
+		Joins(saleJoin, saleArgs...)
+
+	if param.Currency != "" {
+		query = query.Joins("LEFT JOIN product_price ON product_price.product_id = product.id AND product_price.currency = ?", param.Currency).
+			Where("product.currency = ? OR product_price.price IS NOT NULL", param.Currency)
+	}
+
+	return query
+}
@@ -4,98 +4,372 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"product/infrastructure/log"
 	"product/models"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
 var (
 	cacheKeyProductInfo         = "product:%d"
 	cacheKeyProductCateogryInfo = "product_category:%d"
+	cacheKeyCategoryBreadcrumbs = "product_category_breadcrumbs:%d"
 )
 
-func (r *ProductRepository) GetProductByIDFromRedis(ctx context.Context, productID int64) (*models.Product, error) {
-	cacheKey := fmt.Sprintf(cacheKeyProductInfo, productID)
+// tombstone stored for ids that do not exist in the database
+const cacheValueNotFound = "__not_found__"
 
-	var product models.Product
+// every cache key has a generation next to it, bumped by each invalidation. a fill carries the generation
+// read before its database read and is dropped once it moved, so a row read before a write cannot be
+// cached after the write invalidated it. the counter outlives any fill by far and then expires
+const (
+	cacheKeyGeneration = "%s:generation"
+	cacheGenerationTTL = 24 * time.Hour
+)
+
+var invalidateScript = redis.NewScript(`
+redis.call("INCR", KEYS[2])
+redis.call("PEXPIRE", KEYS[2], ARGV[1])
+return redis.call("DEL", KEYS[1])
+`)
+
+var fillScript = redis.NewScript(`
+local generation = redis.call("GET", KEYS[2]) or "0"
+if generation ~= ARGV[1] then
+	return 0
+end
+return redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+`)
+
+const (
+	invalidationQueueSize    = 1024
+	invalidationAttempts     = 3
+	invalidationRetryBackoff = 50 * time.Millisecond
+	invalidationRequeueDelay = 1 * time.Second
+)
+
+func (r *ProductRepository) ProductCacheGeneration(ctx context.Context, productID int64) (int64, error) {
+	return r.cacheGeneration(ctx, fmt.Sprintf(cacheKeyProductInfo, productID))
+}
+
+func (r *ProductRepository) ProductCategoryCacheGeneration(ctx context.Context, productCategoryID int64) (int64, error) {
+	return r.cacheGeneration(ctx, fmt.Sprintf(cacheKeyProductCateogryInfo, productCategoryID))
+}
+
+func (r *ProductRepository) CategoryBreadcrumbsCacheGeneration(ctx context.Context, productCategoryID int64) (int64, error) {
+	return r.cacheGeneration(ctx, fmt.Sprintf(cacheKeyCategoryBreadcrumbs, productCategoryID))
+}
+
+// ProductCacheGenerations reads the generation of every id with one MGET
+func (r *ProductRepository) ProductCacheGenerations(ctx context.Context, productIDs []int64) (map[int64]int64, error) {
+	generationKeys := make([]string, len(productIDs))
+	for i, productID := range productIDs {
+		generationKeys[i] = fmt.Sprintf(cacheKeyGeneration, fmt.Sprintf(cacheKeyProductInfo, productID))
+	}
 
-	productStr, err := r.Redis.Get(ctx, cacheKey).Result()
+	values, err := r.Redis.MGet(ctx, generationKeys...).Result()
 	if err != nil {
-		if err == redis.Nil {
-			return &product, nil
+		return nil, err
+	}
+
+	generations := make(map[int64]int64, len(productIDs))
+	for i, value := range values {
+		generation, err := parseGeneration(value)
+		if err != nil {
+			return nil, err
 		}
 
+		generations[productIDs[i]] = generation
+	}
+
+	return generations, nil
+}
+
+func (r *ProductRepository) GetProductByIDFromRedis(ctx context.Context, productID int64) (*models.Product, error) {
+	var product models.Product
+
+	found, err := r.getJSON(ctx, fmt.Sprintf(cacheKeyProductInfo, productID), &product)
+	if err != nil || !found {
 		return nil, err
 	}
 
-	// unmarshal redis string to model struct
-	err = json.Unmarshal([]byte(productStr), &product)
+	return &product, nil
+}
+
+// GetProductsByIDsFromRedis reads every id with one MGET, misses are left out of the map
+// and cached tombstones are present with a nil product
+func (r *ProductRepository) GetProductsByIDsFromRedis(ctx context.Context, productIDs []int64) (map[int64]*models.Product, error) {
+	cacheKeys := make([]string, len(productIDs))
+	for i, productID := range productIDs {
+		cacheKeys[i] = fmt.Sprintf(cacheKeyProductInfo, productID)
+	}
+
+	values, err := r.Redis.MGet(ctx, cacheKeys...).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	return &product, nil
+	products := make(map[int64]*models.Product, len(productIDs))
+
+	for i, value := range values {
+		cached, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		if cached == cacheValueNotFound {
+			products[productIDs[i]] = nil
+
+			continue
+		}
+
+		var product models.Product
+
+		// a broken entry is a miss, the database read will overwrite it
+		err = json.Unmarshal([]byte(cached), &product)
+		if err != nil {
+			log.Logger.Errorf("json.Unmarshal for %s got error %v", cacheKeys[i], err)
+
+			continue
+		}
+
+		products[productIDs[i]] = &product
+	}
+
+	return products, nil
 }
 
 func (r *ProductRepository) GetProductCategoryByIDFromRedis(ctx context.Context, productCategoryID int64) (*models.ProductCategory, error) {
-	cacheKey := fmt.Sprintf(cacheKeyProductCateogryInfo, productCategoryID)
-
 	var productCategory models.ProductCategory
 
-	productCategoryStr, err := r.Redis.Get(ctx, cacheKey).Result()
+	found, err := r.getJSON(ctx, fmt.Sprintf(cacheKeyProductCateogryInfo, productCategoryID), &productCategory)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return &productCategory, nil
+}
+
+func (r *ProductRepository) GetCategoryBreadcrumbsFromRedis(ctx context.Context, productCategoryID int64) (*[]models.CategoryBreadcrumb, error) {
+	var breadcrumbs []models.CategoryBreadcrumb
+
+	found, err := r.getJSON(ctx, fmt.Sprintf(cacheKeyCategoryBreadcrumbs, productCategoryID), &breadcrumbs)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return &breadcrumbs, nil
+}
+
+func (r *ProductRepository) SetCategoryBreadcrumbs(ctx context.Context, breadcrumbs *[]models.CategoryBreadcrumb, productCategoryID int64, generation int64, ttl time.Duration) error {
+	return r.setJSON(ctx, fmt.Sprintf(cacheKeyCategoryBreadcrumbs, productCategoryID), breadcrumbs, generation, ttl)
+}
+
+func (r *ProductRepository) SetCategoryBreadcrumbsNotFound(ctx context.Context, productCategoryID int64, generation int64, ttl time.Duration) error {
+	return r.fill(ctx, fmt.Sprintf(cacheKeyCategoryBreadcrumbs, productCategoryID), cacheValueNotFound, generation, ttl)
+}
+
+func (r *ProductRepository) SetProductByID(ctx context.Context, product *models.Product, productID int64, generation int64, ttl time.Duration) error {
+	return r.setJSON(ctx, fmt.Sprintf(cacheKeyProductInfo, productID), product, generation, ttl)
+}
+
+func (r *ProductRepository) SetProductCategoryByID(ctx context.Context, productCategory *models.ProductCategory, productCategoryID int64, generation int64, ttl time.Duration) error {
+	return r.setJSON(ctx, fmt.Sprintf(cacheKeyProductCateogryInfo, productCategoryID), productCategory, generation, ttl)
+}
+
+// ProductCacheEntry is one key of a pipelined cache fill, a nil Product writes a tombstone.
+// Generation is the one read before the database read
+type ProductCacheEntry struct {
+	ID         int64
+	Product    *models.Product
+	Generation int64
+	TTL        time.Duration
+}
+
+// SetProductsByID writes every entry in a single pipeline round trip
+func (r *ProductRepository) SetProductsByID(ctx context.Context, entries []ProductCacheEntry) error {
+	pipe := r.Redis.Pipeline()
+
+	for _, entry := range entries {
+		cacheKey := fmt.Sprintf(cacheKeyProductInfo, entry.ID)
+
+		var value interface{} = cacheValueNotFound
+		if entry.Product != nil {
+			valueJSON, err := json.Marshal(entry.Product)
+			if err != nil {
+				return err
+			}
+
+			value = valueJSON
+		}
+
+		// a pipeline only sees the NOSCRIPT error of EVALSHA on exec, so it sends the whole script
+		fillScript.Eval(ctx, pipe, fillKeys(cacheKey), entry.Generation, value, entry.TTL.Milliseconds())
+	}
+
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+func (r *ProductRepository) SetProductNotFound(ctx context.Context, productID int64, generation int64, ttl time.Duration) error {
+	return r.fill(ctx, fmt.Sprintf(cacheKeyProductInfo, productID), cacheValueNotFound, generation, ttl)
+}
+
+func (r *ProductRepository) SetProductCategoryNotFound(ctx context.Context, productCategoryID int64, generation int64, ttl time.Duration) error {
+	return r.fill(ctx, fmt.Sprintf(cacheKeyProductCateogryInfo, productCategoryID), cacheValueNotFound, generation, ttl)
+}
+
+// getJSON unmarshal the cached value into dest, a missing key is not an error
+// but a cached tombstone is reported as gorm.ErrRecordNotFound
+func (r *ProductRepository) getJSON(ctx context.Context, cacheKey string, dest interface{}) (bool, error) {
+	cached, err := r.Redis.Get(ctx, cacheKey).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, nil
+			return false, nil
 		}
 
-		return nil, err
+		return false, err
+	}
+
+	if cached == cacheValueNotFound {
+		return false, gorm.ErrRecordNotFound
 	}
 
 	// unmarshal redis string to model struct
-	err = json.Unmarshal([]byte(productCategoryStr), &productCategory)
+	err = json.Unmarshal([]byte(cached), dest)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	return &productCategory, nil
+	return true, nil
 }
 
-func (r *ProductRepository) SetProductByID(ctx context.Context, product *models.Product, productID int64) error {
-	cacheKey := fmt.Sprintf(cacheKeyProductInfo, productID)
+func (r *ProductRepository) setJSON(ctx context.Context, cacheKey string, value interface{}, generation int64, ttl time.Duration) error {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
 
-	productJSON, err := json.Marshal(product)
+	return r.fill(ctx, cacheKey, valueJSON, generation, ttl)
+}
 
-	// untuk debug
-	// deadline, _ := ctx.Deadline()
-	// time.Sleep(8 * time.Second)
-	// fmt.Printf("context deadline %v \n", deadline)
-	// fmt.Printf("context deadline Timerun %v \n", time.Until(deadline))
+// fill sets the key unless an invalidation bumped its generation since the caller read it
+func (r *ProductRepository) fill(ctx context.Context, cacheKey string, value interface{}, generation int64, ttl time.Duration) error {
+	return fillScript.Run(ctx, r.Redis, fillKeys(cacheKey), generation, value, ttl.Milliseconds()).Err()
+}
 
+// cacheGeneration is 0 for a key that was never invalidated
+func (r *ProductRepository) cacheGeneration(ctx context.Context, cacheKey string) (int64, error) {
+	value, err := r.Redis.Get(ctx, fmt.Sprintf(cacheKeyGeneration, cacheKey)).Result()
 	if err != nil {
-		return err
+		if err == redis.Nil {
+			return 0, nil
+		}
+
+		return 0, err
 	}
 
-	err = r.Redis.SetEx(ctx, cacheKey, productJSON, 5*time.Minute).Err()
-	if err != nil {
-		return err
+	return parseGeneration(value)
+}
+
+func parseGeneration(value interface{}) (int64, error) {
+	generation, ok := value.(string)
+	if !ok {
+		return 0, nil
 	}
 
-	return nil
+	return strconv.ParseInt(generation, 10, 64)
 }
 
-func (r *ProductRepository) SetProductCategoryByID(ctx context.Context, productCategory *models.ProductCategory, productCategoryID int64) error {
-	cacheKey := fmt.Sprintf(cacheKeyProductCateogryInfo, productCategoryID)
+func fillKeys(cacheKey string) []string {
+	return []string{cacheKey, fmt.Sprintf(cacheKeyGeneration, cacheKey)}
+}
 
-	productCategoryJSON, err := json.Marshal(productCategory)
-	if err != nil {
-		return err
+// cache invalidation
+func (r *ProductRepository) InvalidateProductCache(ctx context.Context, productIDs ...int64) {
+	for _, productID := range productIDs {
+		r.invalidate(ctx, fmt.Sprintf(cacheKeyProductInfo, productID))
 	}
+}
 
-	err = r.Redis.SetEx(ctx, cacheKey, productCategoryJSON, 1*time.Minute).Err()
-	if err != nil {
-		return err
+// InvalidateProductCategoryCache drops the categories and their breadcrumbs, a rename or move changes
+// the breadcrumbs of every descendant so callers pass the whole subtree
+func (r *ProductRepository) InvalidateProductCategoryCache(ctx context.Context, productCategoryIDs ...int64) {
+	for _, productCategoryID := range productCategoryIDs {
+		r.invalidate(ctx, fmt.Sprintf(cacheKeyProductCateogryInfo, productCategoryID))
+		r.invalidate(ctx, fmt.Sprintf(cacheKeyCategoryBreadcrumbs, productCategoryID))
+	}
+}
+
+// invalidate retries the delete a few times, then hands the key to the background worker instead of dropping it
+func (r *ProductRepository) invalidate(ctx context.Context, cacheKey string) {
+	err := r.deleteWithRetry(ctx, cacheKey)
+	if err == nil {
+		return
 	}
 
-	return nil
+	select {
+	case r.invalidationQueue <- cacheKey:
+		log.Logger.Warnf("cache invalidation for %s queued after error %v", cacheKey, err)
+	default:
+		log.Logger.Errorf("cache invalidation queue full, dropping %s after error %v", cacheKey, err)
+	}
+}
+
+func (r *ProductRepository) deleteWithRetry(ctx context.Context, cacheKey string) error {
+	var err error
+
+	for attempt := 0; attempt < invalidationAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(invalidationRetryBackoff * time.Duration(attempt)):
+			}
+		}
+
+		err = invalidateScript.Run(ctx, r.Redis, fillKeys(cacheKey), cacheGenerationTTL.Milliseconds()).Err()
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// worker, keeps retrying queued invalidations until ctx is done
+func (r *ProductRepository) RunCacheInvalidationWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case cacheKey := <-r.invalidationQueue:
+			delCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			err := r.deleteWithRetry(delCtx, cacheKey)
+			cancel()
+
+			if err == nil {
+				continue
+			}
+
+			log.Logger.Errorf("r.deleteWithRetry for %s got error %v", cacheKey, err)
+
+			// back off before putting it back so a redis outage does not spin the worker
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(invalidationRequeueDelay):
+			}
+
+			select {
+			case r.invalidationQueue <- cacheKey:
+			default:
+				log.Logger.Errorf("cache invalidation queue full, dropping %s", cacheKey)
+			}
+		}
+	}
 }
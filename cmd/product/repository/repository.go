@@ -8,11 +8,15 @@ import (
 type ProductRepository struct {
 	Redis    *redis.Client
 	Database *gorm.DB
+
+	// cache keys whose invalidation failed, drained by RunCacheInvalidationWorker
+	invalidationQueue chan string
 }
 
 func NewProductRepository(redis *redis.Client, db *gorm.DB) *ProductRepository {
 	return &ProductRepository{
-		Redis:    redis,
-		Database: db,
+		Redis:             redis,
+		Database:          db,
+		invalidationQueue: make(chan string, invalidationQueueSize),
 	}
 }
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"product/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InsertPriceSchedule adds a sale to a live product, the product row lock serializes the overlap check
+func (r *ProductRepository) InsertPriceSchedule(ctx context.Context, schedule *models.PriceSchedule) (int64, error) {
+	err := r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		err := audit.track(schedule.ProductID)
+		if err != nil {
+			return err
+		}
+
+		err = r.ensureProductLive(tx, schedule.ProductID)
+		if err != nil {
+			return err
+		}
+
+		var overlapping int64
+		err = tx.Table("product_price_schedule").
+			Where("product_id = ? AND starts_at < ? AND ends_at > ?", schedule.ProductID, schedule.EndsAt, schedule.StartsAt).
+			Count(&overlapping).Error
+		if err != nil {
+			return err
+		}
+
+		if overlapping > 0 {
+			return models.ErrScheduleOverlap
+		}
+
+		return tx.Table("product_price_schedule").Create(schedule).Error
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return schedule.ID, nil
+}
+
+func (r *ProductRepository) DeletePriceSchedule(ctx context.Context, productID int64, scheduleID int64) error {
+	return r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		err := audit.track(productID)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Table("product_price_schedule").
+			Where("id = ? AND product_id = ?", scheduleID, productID).
+			Delete(&models.PriceSchedule{})
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+// NextPriceBoundary is the earliest start or end of a sale after the given time, nil when none is scheduled
+func (r *ProductRepository) NextPriceBoundary(ctx context.Context, after time.Time) (*time.Time, error) {
+	var boundary *time.Time
+	err := r.Database.WithContext(ctx).Raw(`SELECT min(boundary) FROM (
+		SELECT min(starts_at) AS boundary FROM product_price_schedule WHERE starts_at > ?
+		UNION ALL
+		SELECT min(ends_at) FROM product_price_schedule WHERE ends_at > ?) AS boundaries`, after, after).
+		Scan(&boundary).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return boundary, nil
+}
+
+// FindProductIDsWithPriceBoundary lists the products whose sale started or ended in (from, to]
+func (r *ProductRepository) FindProductIDsWithPriceBoundary(ctx context.Context, from time.Time, to time.Time) ([]int64, error) {
+	var productIDs []int64
+	err := r.Database.WithContext(ctx).Table("product_price_schedule").
+		Where("(starts_at > ? AND starts_at <= ?) OR (ends_at > ? AND ends_at <= ?)", from, to, from, to).
+		Distinct().
+		Pluck("product_id", &productIDs).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return productIDs, nil
+}
+
+// attachPriceSchedules fills PriceSchedules of every product with the sales that have not ended yet, earliest first
+func (r *ProductRepository) attachPriceSchedules(db *gorm.DB, products ...*models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	productIDs := make([]int64, len(products))
+	for i, product := range products {
+		productIDs[i] = product.ID
+	}
+
+	var schedules []models.PriceSchedule
+	err := db.Table("product_price_schedule").
+		Where("product_id IN ? AND ends_at > ?", productIDs, time.Now()).
+		Order("product_id, starts_at").
+		Find(&schedules).Error
+	if err != nil {
+		return err
+	}
+
+	byProduct := make(map[int64][]models.PriceSchedule, len(products))
+	for _, schedule := range schedules {
+		byProduct[schedule.ProductID] = append(byProduct[schedule.ProductID], schedule)
+	}
+
+	for _, product := range products {
+		product.PriceSchedules = byProduct[product.ID]
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"fmt"
+	"product/models"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+const maxSortKeys = 5
+
+type sortColumn struct {
+	column string
+	// direction used when the key has no "-" prefix
+	desc bool
+	// column is an expression that only exists when the query has a search term
+	search bool
+}
+
+// both reference the "search" relation joined by SearchProduct, so they carry no placeholders
+const (
+	relevanceExpression = "(ts_rank(product.search_vector, search.query) + similarity(product.name, search.term))"
+	snippetExpression   = "ts_headline('simple', coalesce(nullif(product.description, ''), product.name), search.query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2')"
+)
+
+// public sort keys, nothing outside this map ever reaches ORDER BY
+var productSortColumns = map[string]sortColumn{
+	"name":     {column: "product.name"},
+	"price":    {column: "product.price"},
+	"stock":    {column: "product.stock"},
+	"category": {column: "product_category.name"},
+	"newest":   {column: "product.id", desc: true},
+
+	"relevance": {column: relevanceExpression, desc: true, search: true},
+
+	// column names accepted by the old order_by parameter
+	"product.name":  {column: "product.name"},
+	"product.price": {column: "product.price"},
+	"product.stock": {column: "product.stock"},
+}
+
+// productOrderBy turns "-price,name" into ORDER BY columns. the legacy order_by + sort=ASC|DESC pair
+// is still understood, product.id is always appended so the order is total
+func productOrderBy(orderBy string, sort string, hasSearch bool) ([]clause.OrderByColumn, error) {
+	spec := sort
+	legacyDirection := strings.ToUpper(strings.TrimSpace(sort))
+
+	if legacyDirection == "ASC" || legacyDirection == "DESC" || orderBy != "" {
+		// a bare sort=DESC reverses the default key
+		spec = orderBy
+		if strings.TrimSpace(spec) == "" {
+			spec = "name"
+		}
+
+		if legacyDirection == "DESC" {
+			spec = "-" + spec
+		}
+	}
+
+	if strings.TrimSpace(spec) == "" || spec == "-" {
+		spec = "name"
+	}
+
+	keys := strings.Split(spec, ",")
+	if len(keys) > maxSortKeys {
+		return nil, fmt.Errorf("%w: at most %d sort keys are allowed", models.ErrInvalidSort, maxSortKeys)
+	}
+
+	columns := make([]clause.OrderByColumn, 0, len(keys)+1)
+	seen := map[string]bool{}
+
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		reverse := strings.HasPrefix(key, "-")
+		key = strings.TrimPrefix(key, "-")
+
+		sortCol, ok := productSortColumns[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown sort key %q, allowed keys are name, price, stock, category, newest, relevance", models.ErrInvalidSort, key)
+		}
+
+		if sortCol.search && !hasSearch {
+			return nil, fmt.Errorf("%w: sort key %q needs a search term in name", models.ErrInvalidSort, key)
+		}
+
+		if seen[sortCol.column] {
+			continue
+		}
+		seen[sortCol.column] = true
+
+		columns = append(columns, clause.OrderByColumn{
+			Column: clause.Column{Name: sortCol.column, Raw: sortCol.search},
+			Desc:   sortCol.desc != reverse,
+		})
+	}
+
+	if !seen["product.id"] {
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: "product.id"}})
+	}
+
+	return columns, nil
+}
+
+// keysetCondition builds (c1 > v1) OR (c1 = v1 AND c2 > v2) ... for the cursor row,
+// comparisons flip for descending columns and again when paging backward
+func keysetCondition(orderBy []clause.OrderByColumn, cursor *models.SearchCursor) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	for i, column := range orderBy {
+		var parts []string
+
+		for _, previous := range orderBy[:i] {
+			parts = append(parts, previous.Column.Name+" = ?")
+			args = append(args, cursorValue(cursor, previous.Column.Name))
+		}
+
+		operator := ">"
+		if column.Desc != cursor.Backward {
+			operator = "<"
+		}
+
+		parts = append(parts, column.Column.Name+" "+operator+" ?")
+		args = append(args, cursorValue(cursor, column.Column.Name))
+
+		conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+func reverseOrderBy(orderBy []clause.OrderByColumn) []clause.OrderByColumn {
+	reversed := make([]clause.OrderByColumn, len(orderBy))
+	for i, column := range orderBy {
+		column.Desc = !column.Desc
+		reversed[i] = column
+	}
+
+	return reversed
+}
+
+func cursorValue(cursor *models.SearchCursor, column string) interface{} {
+	switch column {
+	case "product.name":
+		return cursor.Name
+	case "product.price":
+		return cursor.Price
+	case "product.stock":
+		return cursor.Stock
+	case "product_category.name":
+		return cursor.Category
+	case relevanceExpression:
+		return cursor.Relevance
+	default:
+		return cursor.ID
+	}
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"errors"
+	"product/models"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// orderBySQL renders the ORDER BY of a dry run query, without a database
+func orderBySQL(t *testing.T, columns []clause.OrderByColumn) string {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var products []models.Product
+	stmt := db.Table("product").Order(clause.OrderBy{Columns: columns}).Find(&products).Statement
+
+	sql := stmt.SQL.String()
+	index := strings.Index(sql, "ORDER BY ")
+	if index < 0 {
+		t.Fatalf("no ORDER BY in %s", sql)
+	}
+
+	return sql[index+len("ORDER BY "):]
+}
+
+func TestProductOrderBy(t *testing.T) {
+	tests := []struct {
+		name      string
+		orderBy   string
+		sort      string
+		hasSearch bool
+		want      string
+	}{
+		{
+			name: "default",
+			want: `"product"."name","product"."id"`,
+		},
+		{
+			name: "multi key",
+			sort: "-price,name",
+			want: `"product"."price" DESC,"product"."name","product"."id"`,
+		},
+		{
+			name: "spaces around keys",
+			sort: " stock , -category ",
+			want: `"product"."stock","product_category"."name" DESC,"product"."id"`,
+		},
+		{
+			name: "newest descends and is the tiebreaker",
+			sort: "price,newest",
+			want: `"product"."price","product"."id" DESC`,
+		},
+		{
+			name: "reversed newest",
+			sort: "-newest",
+			want: `"product"."id"`,
+		},
+		{
+			name: "repeated column kept once",
+			sort: "price,-product.price,name",
+			want: `"product"."price","product"."name","product"."id"`,
+		},
+		{
+			name:      "relevance with a search term",
+			sort:      "relevance,-price",
+			hasSearch: true,
+			want:      relevanceExpression + ` DESC,"product"."price" DESC,"product"."id"`,
+		},
+		{
+			name: "legacy descending without order_by",
+			sort: "DESC",
+			want: `"product"."name" DESC,"product"."id"`,
+		},
+		{
+			name: "legacy ascending without order_by",
+			sort: "asc",
+			want: `"product"."name","product"."id"`,
+		},
+		{
+			name:    "legacy order_by ascending",
+			orderBy: "product.price",
+			sort:    "ASC",
+			want:    `"product"."price","product"."id"`,
+		},
+		{
+			name:    "legacy order_by descending",
+			orderBy: "product.stock",
+			sort:    "desc",
+			want:    `"product"."stock" DESC,"product"."id"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			columns, err := productOrderBy(test.orderBy, test.sort, test.hasSearch)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := orderBySQL(t, columns); got != test.want {
+				t.Errorf("got ORDER BY %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestProductOrderByRejects(t *testing.T) {
+	tests := []struct {
+		name      string
+		orderBy   string
+		sort      string
+		hasSearch bool
+	}{
+		{name: "statement after a key", sort: "price;DROP TABLE product"},
+		{name: "direction and subquery", sort: "name desc, (select 1)"},
+		{name: "comment after a key", sort: "-price,name--"},
+		{name: "legacy order_by with an expression", orderBy: "product.price; DELETE FROM product", sort: "ASC"},
+		{name: "unknown column", sort: "product.description"},
+		{name: "empty key", sort: "price,,name"},
+		{name: "double minus", sort: "--price"},
+		{name: "relevance without a search term", sort: "relevance"},
+		{name: "too many keys", sort: "name,price,stock,category,newest,-name"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			columns, err := productOrderBy(test.orderBy, test.sort, test.hasSearch)
+			if !errors.Is(err, models.ErrInvalidSort) {
+				t.Fatalf("got %v with error %v, want ErrInvalidSort", columns, err)
+			}
+		})
+	}
+}
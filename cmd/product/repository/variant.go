@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"product/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// variants belong to a product and follow it through soft delete and restore, only a live product takes variant writes
+
+func (r *ProductRepository) InsertProductVariant(ctx context.Context, variant *models.ProductVariant) (int64, error) {
+	variant.Version = 1
+
+	err := r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		err := audit.track(variant.ProductID)
+		if err != nil {
+			return err
+		}
+
+		err = r.touchProduct(tx, variant.ProductID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Table("product_variant").Create(variant).Error
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return variant.ID, nil
+}
+
+// UpdateProductVariant writes every field with optimistic locking, a non zero variant.Version must match the stored row
+func (r *ProductRepository) UpdateProductVariant(ctx context.Context, variant *models.ProductVariant) (*models.ProductVariant, error) {
+	err := r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		err := audit.track(variant.ProductID)
+		if err != nil {
+			return err
+		}
+
+		err = r.touchProduct(tx, variant.ProductID)
+		if err != nil {
+			return err
+		}
+
+		var current models.ProductVariant
+		err = tx.Table("product_variant").Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND product_id = ?", variant.ID, variant.ProductID).Take(&current).Error
+		if err != nil {
+			return err
+		}
+
+		if variant.Version > 0 && variant.Version != current.Version {
+			return models.ErrVersionConflict
+		}
+
+		variant.Version = current.Version + 1
+
+		// a struct update so options go through the json serializer, Select also writes a nil price
+		return tx.Table("product_variant").Where("id = ?", variant.ID).
+			Select("sku", "options", "price", "stock", "version").Updates(variant).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return variant, nil
+}
+
+func (r *ProductRepository) DeleteProductVariant(ctx context.Context, productID int64, variantID int64) error {
+	return r.withHistory(ctx, func(tx *gorm.DB, audit *productAudit) error {
+		err := audit.track(productID)
+		if err != nil {
+			return err
+		}
+
+		err = r.touchProduct(tx, productID)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Table("product_variant").Where("id = ? AND product_id = ?", variantID, productID).Delete(&models.ProductVariant{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return nil
+	})
+}
+
+// touchProduct bumps the version of the product whose variants change, the variants are part of the
+// product so an edit holding the older ETag conflicts. a soft deleted product is not found
+func (r *ProductRepository) touchProduct(tx *gorm.DB, productID int64) error {
+	result := tx.Table("product").Where("id = ? AND deleted_at IS NULL", productID).Update("version", gorm.Expr("version + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// attachVariants fills Variants of every product with a single query, ordered by id
+func (r *ProductRepository) attachVariants(db *gorm.DB, products ...*models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	productIDs := make([]int64, len(products))
+	for i, product := range products {
+		productIDs[i] = product.ID
+	}
+
+	var variants []models.ProductVariant
+	err := db.Table("product_variant").Where("product_id IN ?", productIDs).Order("id").Find(&variants).Error
+	if err != nil {
+		return err
+	}
+
+	byProduct := make(map[int64][]models.ProductVariant, len(products))
+	for _, variant := range variants {
+		byProduct[variant.ProductID] = append(byProduct[variant.ProductID], variant)
+	}
+
+	for _, product := range products {
+		product.Variants = byProduct[product.ID]
+	}
+
+	return nil
+}
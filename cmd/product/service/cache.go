@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"product/cmd/product/repository"
+	"product/config"
+	"product/infrastructure/log"
+	"product/models"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// cacheSource wires one entity into cacheAside, the setters take the generation read before find
+type cacheSource[T any] struct {
+	entity      string
+	ttl         time.Duration
+	getCache    func(context.Context, int64) (*T, error)
+	generation  func(context.Context, int64) (int64, error)
+	find        func(context.Context, int64) (*T, error)
+	setCache    func(context.Context, *T, int64, int64, time.Duration) error
+	setNotFound func(context.Context, int64, int64, time.Duration) error
+}
+
+// cacheAside serves an entity from redis and falls back to the database on a miss.
+// a redis error is treated as a miss so a cache outage never fails the read.
+// concurrent misses on the same key share one database read through the singleflight group,
+// ids that do not exist are remembered for a short while and every ttl gets jitter
+// so hot keys written together do not expire together.
+// the fill is dropped when the key was invalidated after the database read, a write that lands
+// between the read and the fill would otherwise have its old row cached until the ttl runs out
+func cacheAside[T any](ctx context.Context, group *singleflight.Group, cfg config.CacheConfig, src cacheSource[T], id int64) (*T, error) {
+	cached, err := src.getCache(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"entity": src.entity,
+			"id":     id,
+		}).Errorf("get %s from redis got error %v", src.entity, err)
+	}
+
+	if cached != nil {
+		return cached, nil
+	}
+
+	flight := group.DoChan(fmt.Sprintf("%s:%d", src.entity, id), func() (interface{}, error) {
+		// the flight outlives any single caller, so it must not die with the caller's ctx
+		findCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+
+		// without a generation the fill could not tell a stale row, so it is skipped
+		generation, genErr := src.generation(findCtx, id)
+		if genErr != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"entity": src.entity,
+				"id":     id,
+			}).Errorf("get %s cache generation got error %v", src.entity, genErr)
+		}
+
+		// get from db
+		value, err := src.find(findCtx, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) && genErr == nil {
+				go fillCache(src.entity, id, func(ctx context.Context) error {
+					return src.setNotFound(ctx, id, generation, cfg.NotFoundTTL)
+				})
+			}
+
+			return nil, err
+		}
+
+		// fill in the background so the callers do not wait for redis
+		if genErr == nil {
+			go fillCache(src.entity, id, func(ctx context.Context) error {
+				return src.setCache(ctx, value, id, generation, jitter(src.ttl, cfg.TTLJitter))
+			})
+		}
+
+		return value, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-flight:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+
+		return result.Val.(*T), nil
+	}
+}
+
+func fillCache(entity string, id int64, set func(context.Context) error) {
+	ctxDetach, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := set(ctxDetach)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"entity": entity,
+			"id":     id,
+		}).Errorf("set %s to redis got error %v", entity, err)
+	}
+}
+
+func jitter(ttl time.Duration, maxJitter time.Duration) time.Duration {
+	if maxJitter <= 0 {
+		return ttl
+	}
+
+	return ttl + rand.N(maxJitter)
+}
+
+// batchCacheAside is cacheAside for many product ids at once: one MGET, one IN query for the misses
+// and one pipelined backfill. tombstoned and missing ids come back as nil entries.
+func (s *ProductService) batchCacheAside(ctx context.Context, productIDs []int64) (map[int64]*models.Product, error) {
+	cfg := s.Config.Cache
+
+	products, err := s.ProductRepository.GetProductsByIDsFromRedis(ctx, productIDs)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productIDs": productIDs,
+		}).Errorf("s.ProductRepository.GetProductsByIDsFromRedis got error %v", err)
+
+		products = make(map[int64]*models.Product, len(productIDs))
+	}
+
+	var misses []int64
+	for _, productID := range productIDs {
+		if _, ok := products[productID]; !ok {
+			misses = append(misses, productID)
+		}
+	}
+
+	if len(misses) == 0 {
+		return products, nil
+	}
+
+	// read before the database, see cacheAside
+	generations, genErr := s.ProductRepository.ProductCacheGenerations(ctx, misses)
+	if genErr != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productIDs": misses,
+		}).Errorf("s.ProductRepository.ProductCacheGenerations got error %v", genErr)
+	}
+
+	found, err := s.ProductRepository.FindProductsByIDs(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range found {
+		products[found[i].ID] = &found[i]
+	}
+
+	entries := make([]repository.ProductCacheEntry, 0, len(misses))
+	for _, productID := range misses {
+		product, ok := products[productID]
+		if !ok {
+			products[productID] = nil
+			entries = append(entries, repository.ProductCacheEntry{ID: productID, Generation: generations[productID], TTL: cfg.NotFoundTTL})
+
+			continue
+		}
+
+		entries = append(entries, repository.ProductCacheEntry{
+			ID:         productID,
+			Product:    product,
+			Generation: generations[productID],
+			TTL:        jitter(cfg.ProductTTL, cfg.TTLJitter),
+		})
+	}
+
+	if genErr != nil {
+		return products, nil
+	}
+
+	// fill in the background so the caller does not wait for redis
+	go func() {
+		ctxDetach, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := s.ProductRepository.SetProductsByID(ctxDetach, entries)
+		if err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"productIDs": misses,
+			}).Errorf("s.ProductRepository.SetProductsByID got error %v", err)
+		}
+	}()
+
+	return products, nil
+}
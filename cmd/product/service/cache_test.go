@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"product/cmd/product/repository"
+	"product/config"
+	"product/infrastructure/log"
+	"product/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	log.Logger = logrus.New()
+	log.Logger.SetOutput(io.Discard)
+
+	os.Exit(m.Run())
+}
+
+type testEnv struct {
+	service *ProductService
+	redis   *miniredis.Miniredis
+	mock    sqlmock.Sqlmock
+}
+
+// newTestEnv wires the service to a miniredis and a mocked postgres, category 1 and its breadcrumbs are
+// cached so product reads and writes only hit the database for the product
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	mr.Set("product_category:1", `{"id":1,"name":"peripherals","version":1}`)
+	mr.Set("product_category_breadcrumbs:1", "[]")
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:         logger.Discard,
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	cfg := &config.Config{Cache: config.CacheConfig{
+		ProductTTL:         time.Minute,
+		ProductCategoryTTL: time.Minute,
+		NotFoundTTL:        time.Minute,
+	}}
+
+	return &testEnv{
+		service: NewProductService(*repository.NewProductRepository(redisClient, db), cfg),
+		redis:   mr,
+		mock:    mock,
+	}
+}
+
+func testProduct() models.Product {
+	return models.Product{
+		ID:         1,
+		Name:       "keyboard",
+		Price:      models.Decimal(1000),
+		Currency:   "USD",
+		Stock:      5,
+		CategoryID: 1,
+		Version:    1,
+	}
+}
+
+func productRows(products ...models.Product) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "price", "currency", "stock", "category_id", "version", "deleted_at"})
+
+	for _, product := range products {
+		var deletedAt interface{}
+		if product.DeletedAt.Valid {
+			deletedAt = product.DeletedAt.Time
+		}
+
+		rows.AddRow(product.ID, product.Name, product.Description, product.Price.String(), product.Currency,
+			product.Stock, product.CategoryID, product.Version, deletedAt)
+	}
+
+	return rows
+}
+
+func categoryRows(productCategoryIDs ...int64) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "name", "parent_id", "version", "deleted_at"})
+
+	for _, productCategoryID := range productCategoryIDs {
+		rows.AddRow(productCategoryID, "peripherals", nil, 1, nil)
+	}
+
+	return rows
+}
+
+// expectFindProduct expects a product lookup by id with its variants, price list and sales, nil finds nothing
+func (e *testEnv) expectFindProduct(product *models.Product) {
+	if product == nil {
+		e.mock.ExpectQuery(`SELECT \* FROM "product" WHERE`).WillReturnRows(productRows())
+
+		return
+	}
+
+	e.mock.ExpectQuery(`SELECT \* FROM "product" WHERE`).WillReturnRows(productRows(*product))
+	e.mock.ExpectQuery(`FROM "product_variant"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	e.mock.ExpectQuery(`FROM "product_price"`).WillReturnRows(sqlmock.NewRows([]string{"product_id"}))
+	e.mock.ExpectQuery(`FROM "product_price_schedule"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+func (e *testEnv) expectUpdateProduct(before models.Product, after models.Product) {
+	e.mock.ExpectBegin()
+	e.expectFindProduct(&before)
+	e.mock.ExpectExec(`UPDATE "product" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	e.mock.ExpectQuery(`SELECT stock FROM product`).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(after.Stock))
+	e.expectFindProduct(&after)
+	e.mock.ExpectQuery(`INSERT INTO "product_history"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	e.mock.ExpectCommit()
+	e.expectFindProduct(&after)
+}
+
+func (e *testEnv) expectDeleteProduct(product models.Product) {
+	deleted := product
+	deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+
+	e.mock.ExpectBegin()
+	e.expectFindProduct(&product)
+	e.mock.ExpectExec(`UPDATE "product" SET "deleted_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	e.expectFindProduct(&deleted)
+	e.mock.ExpectQuery(`INSERT INTO "product_history"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	e.mock.ExpectCommit()
+}
+
+// waitForCache waits for the background fill of key
+func (e *testEnv) waitForCache(t *testing.T, key string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if value, err := e.redis.Get(key); err == nil {
+			return value
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("%s was not cached", key)
+
+	return ""
+}
+
+func (e *testEnv) checkExpectations(t *testing.T) {
+	t.Helper()
+
+	if err := e.mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func expectNotFound(t *testing.T, err error) {
+	t.Helper()
+
+	var serviceErr *Error
+	if !errors.As(err, &serviceErr) || serviceErr.Kind != KindNotFound {
+		t.Fatalf("got error %v, want not found", err)
+	}
+}
+
+func TestUpdateProductInvalidatesCachedRead(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	before := testProduct()
+	after := before
+	after.Name = "mechanical keyboard"
+	after.Version = 2
+
+	env.expectFindProduct(&before)
+
+	product, err := env.service.GetProductByID(ctx, before.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if product.Name != before.Name {
+		t.Fatalf("got name %q, want %q", product.Name, before.Name)
+	}
+
+	env.waitForCache(t, "product:1")
+
+	env.expectUpdateProduct(before, after)
+
+	_, err = env.service.UpdateProduct(ctx, &models.Product{
+		ID:         after.ID,
+		Name:       after.Name,
+		Price:      after.Price,
+		Currency:   after.Currency,
+		Stock:      after.Stock,
+		CategoryID: after.CategoryID,
+		Version:    before.Version,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if env.redis.Exists("product:1") {
+		t.Fatal("product:1 still cached after the update")
+	}
+
+	env.expectFindProduct(&after)
+
+	product, err = env.service.GetProductByID(ctx, after.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if product.Name != after.Name || product.Version != after.Version {
+		t.Fatalf("got %q version %d, want %q version %d", product.Name, product.Version, after.Name, after.Version)
+	}
+
+	env.waitForCache(t, "product:1")
+
+	// served from the refilled cache, the database is not expected again
+	product, err = env.service.GetProductByID(ctx, after.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if product.Name != after.Name {
+		t.Fatalf("got cached name %q, want %q", product.Name, after.Name)
+	}
+
+	env.checkExpectations(t)
+}
+
+func TestDeleteProductInvalidatesCachedRead(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	product := testProduct()
+
+	env.expectFindProduct(&product)
+
+	_, err := env.service.GetProductByID(ctx, product.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.waitForCache(t, "product:1")
+
+	env.expectDeleteProduct(product)
+
+	err = env.service.DeleteProductByID(ctx, product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.expectFindProduct(nil)
+
+	_, err = env.service.GetProductByID(ctx, product.ID, "")
+	expectNotFound(t, err)
+
+	if value := env.waitForCache(t, "product:1"); value != "__not_found__" {
+		t.Fatalf("got cached %q, want the not found tombstone", value)
+	}
+
+	// the tombstone answers without the database
+	_, err = env.service.GetProductByID(ctx, product.ID, "")
+	expectNotFound(t, err)
+
+	env.checkExpectations(t)
+}
+
+// a read that got the row before a delete must not cache it once the delete invalidated the key
+func TestDeleteDuringCacheFillIsNotCached(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	product := testProduct()
+	productRepository := &env.service.ProductRepository
+
+	env.expectFindProduct(&product)
+	env.expectDeleteProduct(product)
+
+	filled := make(chan error, 1)
+
+	cached, err := cacheAside(ctx, env.service.cacheGroup, env.service.Config.Cache, cacheSource[models.Product]{
+		entity:     "product",
+		ttl:        env.service.Config.Cache.ProductTTL,
+		getCache:   productRepository.GetProductByIDFromRedis,
+		generation: productRepository.ProductCacheGeneration,
+		find: func(ctx context.Context, productID int64) (*models.Product, error) {
+			found, err := productRepository.FindProductByID(ctx, productID)
+			if err != nil {
+				return nil, err
+			}
+
+			// the delete commits and invalidates between the database read and the fill
+			return found, env.service.DeleteProductByID(ctx, productID)
+		},
+		setCache: func(ctx context.Context, product *models.Product, productID int64, generation int64, ttl time.Duration) error {
+			err := productRepository.SetProductByID(ctx, product, productID, generation, ttl)
+			filled <- err
+
+			return err
+		},
+		setNotFound: productRepository.SetProductNotFound,
+	}, product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cached.Name != product.Name {
+		t.Fatalf("got name %q, want %q", cached.Name, product.Name)
+	}
+
+	select {
+	case err := <-filled:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the background fill did not run")
+	}
+
+	if env.redis.Exists("product:1") {
+		t.Fatal("the row read before the delete was cached after it")
+	}
+
+	env.expectFindProduct(nil)
+
+	_, err = env.service.GetProductByID(ctx, product.ID, "")
+	expectNotFound(t, err)
+
+	env.checkExpectations(t)
+}
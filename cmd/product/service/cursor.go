@@ -0,0 +1,80 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"product/models"
+	"strings"
+)
+
+// cursors are base64url(json) + "." + base64url(hmac-sha256), opaque to clients and tamper proof
+
+func encodeCursor(secret string, cursor *models.SearchCursor) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signCursor(secret, encoded)), nil
+}
+
+func decodeCursor(secret string, token string) (*models.SearchCursor, error) {
+	invalid := NewValidationError("invalid_cursor", "cursor is invalid or has been tampered with")
+
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, invalid
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, signCursor(secret, encoded)) {
+		return nil, invalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var cursor models.SearchCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, invalid
+	}
+
+	return &cursor, nil
+}
+
+func signCursor(secret string, encoded string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(encoded))
+
+	return mac.Sum(nil)
+}
+
+// cursorSort identifies the ordering a cursor was issued for, a cursor is only valid for the same ordering.
+// prices differ per currency so the currency is part of it, left out when unset to keep older cursors valid
+func cursorSort(param *models.SearchProductParameter) string {
+	sort := param.OrderBy + "|" + param.Sort
+	if param.Currency != "" {
+		sort += "|" + param.Currency
+	}
+
+	return sort
+}
+
+func newCursor(param *models.SearchProductParameter, product models.Product, backward bool) *models.SearchCursor {
+	return &models.SearchCursor{
+		ID:        product.ID,
+		Name:      product.Name,
+		Price:     product.Price,
+		Stock:     product.Stock,
+		Category:  product.Category,
+		Relevance: product.Relevance,
+		Sort:      cursorSort(param),
+		Backward:  backward,
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"product/models"
+
+	"gorm.io/gorm"
+)
+
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindValidation
+	KindNotFound
+	KindConflict
+	KindUnavailable
+	KindUnprocessable
+	KindForbidden
+)
+
+// Error is the domain error returned by the service layer, Code is machine readable and stable
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+// FieldError points at a single invalid input field
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func NewError(kind ErrorKind, code string, message string, err error) *Error {
+	return &Error{
+		Kind:    kind,
+		Code:    code,
+		Message: message,
+		Err:     err,
+	}
+}
+
+func NewValidationError(code string, message string) *Error {
+	return NewError(KindValidation, code, message, nil)
+}
+
+func NewFieldValidationError(fields []FieldError) *Error {
+	err := NewError(KindUnprocessable, "validation_failed", "one or more fields are invalid", nil)
+	err.Fields = fields
+
+	return err
+}
+
+// joinFieldErrors reports the field errors of several checks as one 422, the same shape a failed binding has,
+// so a payload failing more than one check lists every field. an error of any other kind is returned as is
+func joinFieldErrors(errs ...error) error {
+	var fields []FieldError
+
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		var domainErr *Error
+		if !errors.As(err, &domainErr) || domainErr.Kind != KindUnprocessable || len(domainErr.Fields) == 0 {
+			return err
+		}
+
+		fields = append(fields, domainErr.Fields...)
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return NewFieldValidationError(fields)
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// translateError maps repository and driver errors to domain errors, entity names the record in codes and messages
+func translateError(entity string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return err
+	}
+
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return NewError(KindNotFound, entity+"_not_found", fmt.Sprintf("%s not found", entity), err)
+	case errors.Is(err, models.ErrReservationNotFound):
+		return NewError(KindNotFound, "reservation_not_found", "reservation not found", err)
+	case errors.Is(err, models.ErrVersionConflict):
+		return NewError(KindConflict, "version_conflict", fmt.Sprintf("%s has been modified by another request", entity), err)
+	case errors.Is(err, models.ErrInsufficientStock):
+		return NewError(KindConflict, "insufficient_stock", "insufficient stock", err)
+	case errors.Is(err, models.ErrReservationNotActive):
+		return NewError(KindConflict, "reservation_not_active", "reservation is no longer active", err)
+	case errors.Is(err, models.ErrInvalidSort):
+		return NewError(KindValidation, "invalid_sort", err.Error(), err)
+	case errors.Is(err, models.ErrCategoryNotEmpty):
+		return NewError(KindConflict, "product_category_not_empty", "product category still has products, delete with mode reassign or cascade", err)
+	case errors.Is(err, models.ErrCategoryDeleted):
+		return NewError(KindConflict, "product_category_deleted", "the product category is deleted, restore it first", err)
+	case errors.Is(err, models.ErrCategoryCycle):
+		return NewError(KindConflict, "product_category_cycle", "a product category cannot be moved below itself or one of its descendants", err)
+	case errors.Is(err, models.ErrScheduleOverlap):
+		return NewError(KindConflict, "price_schedule_overlap", "the product already has a sale scheduled in that period", err)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return NewError(KindConflict, entity+"_already_exists", fmt.Sprintf("%s already exists", entity), err)
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return NewError(KindValidation, "invalid_reference", fmt.Sprintf("%s references a record that does not exist", entity), err)
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, driver.ErrBadConn), errors.As(err, &netErr):
+		return NewError(KindUnavailable, "dependency_unavailable", "a backing service is unavailable, please retry", err)
+	default:
+		return NewError(KindInternal, "internal_error", "internal server error", err)
+	}
+}
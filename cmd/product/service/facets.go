@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"product/models"
+	"slices"
+	"sort"
+)
+
+var knownFacets = map[string]bool{
+	models.FacetCategory: true,
+	models.FacetPrice:    true,
+	models.FacetStock:    true,
+}
+
+// prepareFacets rejects unknown facets and falls back to the configured price buckets
+func prepareFacets(param *models.SearchProductParameter, defaultBuckets []float64) error {
+	for _, facet := range param.Facets {
+		if !knownFacets[facet] {
+			return NewValidationError("invalid_facet", fmt.Sprintf("unknown facet %q, allowed facets are category, price, stock", facet))
+		}
+	}
+
+	if len(param.PriceBuckets) == 0 {
+		for _, bucket := range defaultBuckets {
+			param.PriceBuckets = append(param.PriceBuckets, models.DecimalFromFloat(bucket))
+		}
+	}
+
+	if len(param.PriceBuckets) == 0 {
+		return NewValidationError("invalid_price_buckets", "price buckets must not be empty")
+	}
+
+	if !slices.IsSorted(param.PriceBuckets) {
+		return NewValidationError("invalid_price_buckets", "price buckets must be in ascending order")
+	}
+
+	return nil
+}
+
+func buildSearchFacets(rows []models.FacetRow, param *models.SearchProductParameter) *models.SearchFacets {
+	facets := &models.SearchFacets{}
+	priceCounts := map[int64]int{}
+
+	for _, row := range rows {
+		switch row.Facet {
+		case models.FacetCategory:
+			facets.Category = append(facets.Category, models.CategoryFacet{
+				ID:    row.Key,
+				Name:  row.Label,
+				Count: row.Count,
+			})
+		case models.FacetPrice:
+			priceCounts[row.Key] = row.Count
+		case models.FacetStock:
+			if facets.Stock == nil {
+				facets.Stock = &models.StockFacet{}
+			}
+
+			if row.Key == 1 {
+				facets.Stock.InStock = row.Count
+			} else {
+				facets.Stock.OutOfStock = row.Count
+			}
+		}
+	}
+
+	sort.SliceStable(facets.Category, func(i, j int) bool {
+		return facets.Category[i].Count > facets.Category[j].Count
+	})
+
+	for _, facet := range param.Facets {
+		switch facet {
+		case models.FacetPrice:
+			facets.Price = priceFacets(param.PriceBuckets, priceCounts)
+		case models.FacetStock:
+			if facets.Stock == nil {
+				facets.Stock = &models.StockFacet{}
+			}
+		}
+	}
+
+	return facets
+}
+
+// priceFacets lists every configured bucket, width_bucket numbers them 1..n and uses 0 for prices below the first bound
+func priceFacets(buckets []models.Decimal, counts map[int64]int) []models.PriceFacet {
+	var facets []models.PriceFacet
+
+	if counts[0] > 0 {
+		facets = append(facets, models.PriceFacet{Max: &buckets[0], Count: counts[0]})
+	}
+
+	for i := range buckets {
+		facet := models.PriceFacet{
+			Min:   &buckets[i],
+			Count: counts[int64(i+1)],
+		}
+
+		if i+1 < len(buckets) {
+			facet.Max = &buckets[i+1]
+		}
+
+		facets = append(facets, facet)
+	}
+
+	return facets
+}
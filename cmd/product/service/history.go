@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"product/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func (s *ProductService) GetProductHistory(ctx context.Context, productID int64, page int, pageSize int) ([]models.ProductHistory, int, error) {
+	history, totalCount, err := s.ProductRepository.FindProductHistory(ctx, productID, page, pageSize)
+	if err != nil {
+		return nil, 0, translateError("product", err)
+	}
+
+	return history, totalCount, nil
+}
+
+// GetProductAsOf is the product as it was at asOf with the price that applied then, never cached.
+// a product that was soft deleted at asOf is only returned withDeleted
+func (s *ProductService) GetProductAsOf(ctx context.Context, productID int64, currency string, asOf time.Time, withDeleted bool) (*models.Product, error) {
+	if asOf.After(time.Now()) {
+		return nil, NewValidationError("invalid_as_of", "as_of must not be in the future")
+	}
+
+	product, err := s.ProductRepository.FindProductAsOf(ctx, productID, asOf)
+	if err != nil {
+		return nil, translateError("product", err)
+	}
+
+	if product.DeletedAt.Valid && !withDeleted {
+		return nil, translateError("product", gorm.ErrRecordNotFound)
+	}
+
+	product, err = pricedAt(product, currency, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.withBreadcrumbs(ctx, product), nil
+}
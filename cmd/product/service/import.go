@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"product/infrastructure/log"
+	"product/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// rows written per transaction, a failing batch only fails its own rows
+const importBatchSize = 500
+
+// ImportProducts upserts the valid rows on their name and reports every row.
+// in dry run nothing is written, the report shows what the import would do
+func (s *ProductService) ImportProducts(ctx context.Context, param *models.ProductImportParameter) (*models.ProductImportReport, error) {
+	report := &models.ProductImportReport{
+		DryRun:            param.DryRun,
+		CategoriesCreated: []string{},
+		Rows:              make([]models.ProductImportResult, len(param.Rows)),
+	}
+
+	firstLine := make(map[string]int, len(param.Rows))
+	var categoryNames []string
+	seenCategory := map[string]bool{}
+
+	for i, row := range param.Rows {
+		report.Rows[i] = models.ProductImportResult{Line: row.Line, Name: row.Name}
+
+		if row.Error != "" {
+			failImportRow(&report.Rows[i], row.Error)
+
+			continue
+		}
+
+		// the name is the upsert key, a second row with it would silently overwrite the first
+		if line, ok := firstLine[row.Name]; ok {
+			failImportRow(&report.Rows[i], fmt.Sprintf("duplicate name, already imported from line %d", line))
+
+			continue
+		}
+
+		firstLine[row.Name] = row.Line
+
+		if !seenCategory[row.Category] {
+			seenCategory[row.Category] = true
+			categoryNames = append(categoryNames, row.Category)
+		}
+	}
+
+	categoryIDs, deletedCategories, err := s.resolveImportCategories(ctx, param, categoryNames, report)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []int
+	for i, row := range param.Rows {
+		if report.Rows[i].Status != "" {
+			continue
+		}
+
+		if deletedCategories[row.Category] {
+			failImportRow(&report.Rows[i], fmt.Sprintf("product category %q is deleted, restore it first", row.Category))
+
+			continue
+		}
+
+		if _, ok := categoryIDs[row.Category]; !ok {
+			failImportRow(&report.Rows[i], fmt.Sprintf("product category %q does not exist", row.Category))
+
+			continue
+		}
+
+		pending = append(pending, i)
+	}
+
+	if param.DryRun {
+		err = s.planImport(ctx, param.Rows, pending, report)
+	} else {
+		err = s.writeImport(ctx, param.Rows, pending, categoryIDs, report)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range report.Rows {
+		switch result.Status {
+		case models.ImportStatusCreated:
+			report.Created++
+		case models.ImportStatusUpdated:
+			report.Updated++
+		case models.ImportStatusFailed:
+			report.Failed++
+		}
+	}
+
+	return report, nil
+}
+
+// resolveImportCategories maps category names to ids, missing ones are created when asked to.
+// a dry run maps them to 0 so their rows still count as importable. soft deleted categories keep their name,
+// they are returned apart and left deleted, only a restore brings them back
+func (s *ProductService) resolveImportCategories(ctx context.Context, param *models.ProductImportParameter, names []string, report *models.ProductImportReport) (map[string]int, map[string]bool, error) {
+	categoryIDs := make(map[string]int, len(names))
+	deleted := map[string]bool{}
+	if len(names) == 0 {
+		return categoryIDs, deleted, nil
+	}
+
+	productCategories, err := s.ProductRepository.FindProductCategoriesByNames(ctx, names)
+	if err != nil {
+		return nil, nil, translateError("product_category", err)
+	}
+
+	addImportCategories(productCategories, categoryIDs, deleted)
+
+	var missing []string
+	for _, name := range names {
+		if _, ok := categoryIDs[name]; !ok && !deleted[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) == 0 || !param.CreateCategories {
+		return categoryIDs, deleted, nil
+	}
+
+	if param.DryRun {
+		report.CategoriesCreated = missing
+
+		for _, name := range missing {
+			categoryIDs[name] = 0
+		}
+
+		return categoryIDs, deleted, nil
+	}
+
+	productCategories, created, err := s.ProductRepository.InsertProductCategoriesByNames(ctx, missing)
+	if err != nil {
+		return nil, nil, translateError("product_category", err)
+	}
+
+	// a name taken meanwhile was not created here
+	if len(created) > 0 {
+		report.CategoriesCreated = created
+	}
+
+	addImportCategories(productCategories, categoryIDs, deleted)
+
+	for _, productCategory := range productCategories {
+		s.ProductRepository.InvalidateProductCategoryCache(ctx, productCategory.ID)
+	}
+
+	return categoryIDs, deleted, nil
+}
+
+func addImportCategories(productCategories []models.ProductCategory, categoryIDs map[string]int, deleted map[string]bool) {
+	for _, productCategory := range productCategories {
+		if productCategory.DeletedAt.Valid {
+			deleted[productCategory.Name] = true
+
+			continue
+		}
+
+		categoryIDs[productCategory.Name] = int(productCategory.ID)
+	}
+}
+
+// prepareImportBatch gives rows without a currency the one of the product they update, or the default for new
+// products, and fails rows whose price has more decimal places than their currency allows.
+// it returns the rows still to import and the ids of the products that already exist by name
+func (s *ProductService) prepareImportBatch(ctx context.Context, rows []models.ProductImportRow, batch []int, report *models.ProductImportReport) ([]int, map[string]int64, error) {
+	names := make([]string, len(batch))
+	for i, index := range batch {
+		names[i] = rows[index].Name
+	}
+
+	existing, err := s.ProductRepository.FindProductsByNames(ctx, names)
+	if err != nil {
+		return nil, nil, translateError("product", err)
+	}
+
+	existingIDs := make(map[string]int64, len(existing))
+	currencies := make(map[string]string, len(existing))
+	for _, product := range existing {
+		existingIDs[product.Name] = product.ID
+		currencies[product.Name] = product.Currency
+	}
+
+	remaining := batch[:0:0]
+	for _, index := range batch {
+		row := &rows[index]
+
+		if row.Currency == "" {
+			row.Currency = models.DefaultCurrency
+			if currency, ok := currencies[row.Name]; ok {
+				row.Currency = currency
+			}
+		}
+
+		if models.CurrencyExponent(row.Currency) < row.Price.Places() {
+			failImportRow(&report.Rows[index], fmt.Sprintf("price must have at most %d decimal places in %s", models.CurrencyExponent(row.Currency), row.Currency))
+
+			continue
+		}
+
+		remaining = append(remaining, index)
+	}
+
+	return remaining, existingIDs, nil
+}
+
+// planImport tells created from updated rows without writing anything
+func (s *ProductService) planImport(ctx context.Context, rows []models.ProductImportRow, pending []int, report *models.ProductImportReport) error {
+	for start := 0; start < len(pending); start += importBatchSize {
+		batch, existingIDs, err := s.prepareImportBatch(ctx, rows, pending[start:min(start+importBatchSize, len(pending))], report)
+		if err != nil {
+			return err
+		}
+
+		for _, index := range batch {
+			if productID, ok := existingIDs[rows[index].Name]; ok {
+				report.Rows[index].Status = models.ImportStatusUpdated
+				report.Rows[index].ProductID = productID
+			} else {
+				report.Rows[index].Status = models.ImportStatusCreated
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *ProductService) writeImport(ctx context.Context, rows []models.ProductImportRow, pending []int, categoryIDs map[string]int, report *models.ProductImportReport) error {
+	for start := 0; start < len(pending); start += importBatchSize {
+		batch, _, err := s.prepareImportBatch(ctx, rows, pending[start:min(start+importBatchSize, len(pending))], report)
+		if err != nil {
+			return err
+		}
+
+		if len(batch) == 0 {
+			continue
+		}
+
+		err = s.writeImportBatch(ctx, rows, batch, categoryIDs, report)
+		if err == nil {
+			continue
+		}
+
+		// the batch rolled back as a whole, its rows are retried one by one so each reports its own failure
+		log.Logger.WithFields(logrus.Fields{
+			"firstLine": rows[batch[0]].Line,
+			"rows":      len(batch),
+		}).Errorf("s.ProductRepository.UpsertProducts got error %v", err)
+
+		for _, index := range batch {
+			err = s.writeImportBatch(ctx, rows, []int{index}, categoryIDs, report)
+			if err != nil {
+				failImportRow(&report.Rows[index], importWriteError(err))
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeImportBatch upserts the rows in one transaction and reports them, nothing is reported on error
+func (s *ProductService) writeImportBatch(ctx context.Context, rows []models.ProductImportRow, batch []int, categoryIDs map[string]int, report *models.ProductImportReport) error {
+	products := make([]models.Product, len(batch))
+	for i, index := range batch {
+		row := rows[index]
+
+		products[i] = models.Product{
+			Name:        row.Name,
+			Description: row.Description,
+			Price:       row.Price,
+			Currency:    row.Currency,
+			Stock:       row.Stock,
+			CategoryID:  categoryIDs[row.Category],
+		}
+	}
+
+	created, err := s.ProductRepository.UpsertProducts(ctx, products)
+	if err != nil {
+		return err
+	}
+
+	productIDs := make([]int64, len(products))
+	for i, index := range batch {
+		productIDs[i] = products[i].ID
+
+		report.Rows[index].ProductID = products[i].ID
+		report.Rows[index].Status = models.ImportStatusUpdated
+
+		if created[i] {
+			report.Rows[index].Status = models.ImportStatusCreated
+		}
+	}
+
+	s.ProductRepository.InvalidateProductCache(ctx, productIDs...)
+
+	return nil
+}
+
+// importWriteError is the report reason of a row the database refused
+func importWriteError(err error) string {
+	var domainErr *Error
+	if errors.As(translateError("product", err), &domainErr) && domainErr.Kind != KindInternal {
+		return domainErr.Message
+	}
+
+	return "product could not be written"
+}
+
+func failImportRow(result *models.ProductImportResult, reason string) {
+	result.Status = models.ImportStatusFailed
+	result.Reason = reason
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"product/models"
+
+	"gorm.io/gorm"
+)
+
+// checkPricePrecision rejects a price with more decimal places than the currency's minor unit,
+// reported like a failed binding rule so clients get the same shape as for any other field
+func checkPricePrecision(field string, price models.Decimal, currency string) error {
+	exponent := models.CurrencyExponent(currency)
+	if price.Places() <= exponent {
+		return nil
+	}
+
+	return NewFieldValidationError([]FieldError{{
+		Field:   field,
+		Rule:    "price_precision",
+		Message: fmt.Sprintf("%s must have at most %d decimal places in %s", field, exponent, currency),
+	}})
+}
+
+// productCurrency is the currency of a stored product, for writes that leave it out
+func (s *ProductService) productCurrency(ctx context.Context, productID int64) (string, error) {
+	product, err := s.ProductRepository.FindProductByID(ctx, productID)
+	if err != nil {
+		return "", translateError("product", err)
+	}
+
+	return product.Currency, nil
+}
+
+// inCurrency returns a copy of product priced in another currency from its price list. variant price overrides
+// and sales are in the product's own currency, so in another currency every variant sells at the list price
+func inCurrency(product *models.Product, currency string) (*models.Product, error) {
+	for _, price := range product.Prices {
+		if price.Currency != currency {
+			continue
+		}
+
+		result := *product
+		result.Price = price.Price
+		result.ListPrice = price.Price
+		result.Currency = currency
+		result.SalePrice = nil
+		result.SaleEndsAt = nil
+		result.PriceSchedules = nil
+
+		result.Variants = make([]models.ProductVariant, len(product.Variants))
+		for i, variant := range product.Variants {
+			variant.Price = nil
+			result.Variants[i] = variant
+		}
+
+		return &result, nil
+	}
+
+	return nil, NewError(KindNotFound, "product_price_not_found", fmt.Sprintf("product has no price in %s", currency), gorm.ErrRecordNotFound)
+}
+
+func (s *ProductService) SetProductPrice(ctx context.Context, param *models.ProductPrice) (*models.ProductPrice, error) {
+	product, err := s.cachedProduct(ctx, param.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	if param.Currency == product.Currency {
+		return nil, NewValidationError("invalid_currency", fmt.Sprintf("%s is the product's own currency, change its price instead", param.Currency))
+	}
+
+	if err := checkPricePrecision("price", param.Price, param.Currency); err != nil {
+		return nil, err
+	}
+
+	err = s.ProductRepository.SetProductPrice(ctx, param)
+	if err != nil {
+		return nil, translateError("product", err)
+	}
+
+	s.ProductRepository.InvalidateProductCache(ctx, param.ProductID)
+
+	return param, nil
+}
+
+func (s *ProductService) DeleteProductPrice(ctx context.Context, productID int64, currency string) error {
+	_, err := s.cachedProduct(ctx, productID)
+	if err != nil {
+		return err
+	}
+
+	err = s.ProductRepository.DeleteProductPrice(ctx, productID, currency)
+	if err != nil {
+		return translateError("product_price", err)
+	}
+
+	s.ProductRepository.InvalidateProductCache(ctx, productID)
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"product/infrastructure/log"
+	"product/models"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sales are resolved on every read from the schedules cached with the product, the scheduler only
+// drops cached products at each sale boundary so the cache does not hold on to ended sales
+
+// pricedAt returns a copy of product with what it sells for at now, in currency when one is given.
+// the cached product is shared between callers and never modified
+func pricedAt(product *models.Product, currency string, now time.Time) (*models.Product, error) {
+	if currency != "" && currency != product.Currency {
+		return inCurrency(product, currency)
+	}
+
+	return withSale(product, now), nil
+}
+
+// withSale returns a copy of product in its own currency with the sale running at now applied
+func withSale(product *models.Product, now time.Time) *models.Product {
+	result := *product
+	result.ListPrice = product.Price
+	result.SalePrice = nil
+	result.SaleEndsAt = nil
+	result.PriceSchedules = nil
+
+	for _, schedule := range product.PriceSchedules {
+		if !schedule.ActiveAt(now) {
+			continue
+		}
+
+		salePrice, endsAt := schedule.SalePrice, schedule.EndsAt
+
+		result.Price = salePrice
+		result.SalePrice = &salePrice
+		result.SaleEndsAt = &endsAt
+
+		break
+	}
+
+	return &result
+}
+
+// GetPriceSchedules lists the sales of a product that have not ended yet
+func (s *ProductService) GetPriceSchedules(ctx context.Context, productID int64) ([]models.PriceSchedule, error) {
+	product, err := s.cachedProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	schedules := []models.PriceSchedule{}
+	for _, schedule := range product.PriceSchedules {
+		if schedule.EndsAt.After(now) {
+			schedules = append(schedules, schedule)
+		}
+	}
+
+	return schedules, nil
+}
+
+func (s *ProductService) CreatePriceSchedule(ctx context.Context, param *models.PriceSchedule) (int64, error) {
+	product, err := s.cachedProduct(ctx, param.ProductID)
+	if err != nil {
+		return 0, err
+	}
+
+	if !param.EndsAt.After(time.Now()) {
+		return 0, NewValidationError("invalid_price_schedule", "ends_at must be in the future")
+	}
+
+	if err := checkPricePrecision("sale_price", param.SalePrice, product.Currency); err != nil {
+		return 0, err
+	}
+
+	scheduleID, err := s.ProductRepository.InsertPriceSchedule(ctx, param)
+	if err != nil {
+		return 0, translateError("product", err)
+	}
+
+	s.ProductRepository.InvalidateProductCache(ctx, param.ProductID)
+
+	return scheduleID, nil
+}
+
+func (s *ProductService) DeletePriceSchedule(ctx context.Context, productID int64, scheduleID int64) error {
+	err := s.ProductRepository.DeletePriceSchedule(ctx, productID, scheduleID)
+	if err != nil {
+		return translateError("price_schedule", err)
+	}
+
+	s.ProductRepository.InvalidateProductCache(ctx, productID)
+
+	return nil
+}
+
+// RunPriceScheduler invalidates the cached products whose sale starts or ends, waking at the next boundary.
+// it sleeps at most CheckInterval so schedules created meanwhile are picked up, until ctx is done
+func (s *ProductService) RunPriceScheduler(ctx context.Context) {
+	checked := time.Now()
+
+	for {
+		wait := s.Config.Pricing.ScheduleCheckInterval
+
+		next, err := s.ProductRepository.NextPriceBoundary(ctx, checked)
+		if err != nil {
+			log.Logger.Errorf("s.ProductRepository.NextPriceBoundary got error %v", err)
+		} else if next != nil && time.Until(*next) < wait {
+			wait = max(time.Until(*next), 0)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+
+		productIDs, err := s.ProductRepository.FindProductIDsWithPriceBoundary(ctx, checked, now)
+		if err != nil {
+			log.Logger.Errorf("s.ProductRepository.FindProductIDsWithPriceBoundary got error %v", err)
+
+			continue
+		}
+
+		s.ProductRepository.InvalidateProductCache(ctx, productIDs...)
+		checked = now
+
+		if len(productIDs) > 0 {
+			log.Logger.WithFields(logrus.Fields{
+				"products": len(productIDs),
+			}).Info("Sale prices changed, product cache invalidated.")
+		}
+	}
+}
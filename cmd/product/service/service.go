@@ -2,141 +2,708 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"product/cmd/product/repository"
+	"product/config"
 	"product/infrastructure/log"
 	"product/models"
+	"slices"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
 )
 
+// bounds ids and exclude_ids so a single search cannot build an unbounded IN list
+const maxSearchIDs = 100
+
 type ProductService struct {
 	ProductRepository repository.ProductRepository
+	Config            *config.Config
+
+	// coalesces concurrent cache misses per key
+	cacheGroup *singleflight.Group
 }
 
 // function contructor
-func NewProductService(productRepository repository.ProductRepository) *ProductService {
+func NewProductService(productRepository repository.ProductRepository, cfg *config.Config) *ProductService {
 	return &ProductService{
 		ProductRepository: productRepository,
+		Config:            cfg,
+		cacheGroup:        &singleflight.Group{},
 	}
 }
 
 // service
-func (s *ProductService) GetProductByID(ctx context.Context, productID int64) (*models.Product, error) {
-	// get cache from redis
-	product, err := s.ProductRepository.GetProductByIDFromRedis(ctx, productID)
+// GetProductByID prices the product in currency from its price list, empty keeps its own currency
+func (s *ProductService) GetProductByID(ctx context.Context, productID int64, currency string) (*models.Product, error) {
+	product, err := s.cachedProduct(ctx, productID)
 	if err != nil {
-		log.Logger.WithFields(logrus.Fields{
-			"productID": productID,
-		}).Errorf("s.ProductRepository.GetProductByIDFromRedis got error %v", err)
+		return nil, err
 	}
 
-	if product.ID != 0 {
-		return product, nil
+	product, err = pricedAt(product, currency, time.Now())
+	if err != nil {
+		return nil, err
 	}
 
-	// get from db
-	product, err = s.ProductRepository.FindProductByID(ctx, productID)
+	return s.withBreadcrumbs(ctx, product), nil
+}
+
+// cachedProduct is the live product with its variants as cached, callers must not modify it
+func (s *ProductService) cachedProduct(ctx context.Context, productID int64) (*models.Product, error) {
+	product, err := cacheAside(ctx, s.cacheGroup, s.Config.Cache, cacheSource[models.Product]{
+		entity:      "product",
+		ttl:         s.Config.Cache.ProductTTL,
+		getCache:    s.ProductRepository.GetProductByIDFromRedis,
+		generation:  s.ProductRepository.ProductCacheGeneration,
+		find:        s.ProductRepository.FindProductByID,
+		setCache:    s.ProductRepository.SetProductByID,
+		setNotFound: s.ProductRepository.SetProductNotFound,
+	}, productID)
 
+	if err != nil {
+		return nil, translateError("product", err)
+	}
+
+	return product, nil
+}
+
+// BatchGetProducts answers in request order, duplicated ids are read once
+func (s *ProductService) BatchGetProducts(ctx context.Context, productIDs []int64) ([]models.BatchGetProductResult, error) {
+	uniqueIDs := make([]int64, 0, len(productIDs))
+	seen := make(map[int64]bool, len(productIDs))
+
+	for _, productID := range productIDs {
+		if !seen[productID] {
+			seen[productID] = true
+			uniqueIDs = append(uniqueIDs, productID)
+		}
+	}
+
+	products, err := s.batchCacheAside(ctx, uniqueIDs)
+	if err != nil {
+		return nil, translateError("product", err)
+	}
+
+	now := time.Now()
+	results := make([]models.BatchGetProductResult, len(productIDs))
+	for i, productID := range productIDs {
+		product := products[productID]
+		if product != nil {
+			product = withSale(product, now)
+		}
+
+		results[i] = models.BatchGetProductResult{
+			ID:      productID,
+			Found:   product != nil,
+			Product: product,
+		}
+	}
+
+	return results, nil
+}
+
+// GetProductByIDWithDeleted skips the cache, it only ever holds live products
+func (s *ProductService) GetProductByIDWithDeleted(ctx context.Context, productID int64, currency string) (*models.Product, error) {
+	product, err := s.ProductRepository.FindProductByIDWithDeleted(ctx, productID)
+	if err != nil {
+		return nil, translateError("product", err)
+	}
+
+	product, err = pricedAt(product, currency, time.Now())
 	if err != nil {
 		return nil, err
 	}
 
-	// go routine usecase here
-	// so every time redis cache is missed, system need to refetch from database
-	// after that set it into Redis with expiry date
-	// but I want to set the redis cache in the background so user does not have to wait.
-	go func(product *models.Product, productID int64) {
-		ctxDetach, cancelRedis := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancelRedis()
-		err := s.ProductRepository.SetProductByID(ctxDetach, product, productID)
-		if err != nil {
-			log.Logger.WithFields(logrus.Fields{
-				"productID": productID,
-			}).Errorf("s.ProductRepository.SetProductByID got error %v", ctx.Err())
+	return s.withBreadcrumbs(ctx, product), nil
+}
+
+// withBreadcrumbs returns a copy of product with the path to its category, the cached product may be
+// shared with other callers so it is never modified. breadcrumbs are best effort and left out on error
+func (s *ProductService) withBreadcrumbs(ctx context.Context, product *models.Product) *models.Product {
+	breadcrumbs, err := cacheAside(ctx, s.cacheGroup, s.Config.Cache, cacheSource[[]models.CategoryBreadcrumb]{
+		entity:      "product_category_breadcrumbs",
+		ttl:         s.Config.Cache.ProductCategoryTTL,
+		getCache:    s.ProductRepository.GetCategoryBreadcrumbsFromRedis,
+		generation:  s.ProductRepository.CategoryBreadcrumbsCacheGeneration,
+		find:        s.ProductRepository.FindCategoryBreadcrumbs,
+		setCache:    s.ProductRepository.SetCategoryBreadcrumbs,
+		setNotFound: s.ProductRepository.SetCategoryBreadcrumbsNotFound,
+	}, int64(product.CategoryID))
+
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID":  product.ID,
+			"categoryID": product.CategoryID,
+		}).Errorf("get category breadcrumbs got error %v", err)
+
+		return product
+	}
+
+	result := *product
+	result.Breadcrumbs = *breadcrumbs
+
+	return &result
+}
+
+// GetProductCategoryTree returns the category with its live descendants nested below it
+func (s *ProductService) GetProductCategoryTree(ctx context.Context, productCategoryID int64) (*models.CategoryNode, error) {
+	productCategories, err := s.ProductRepository.FindCategorySubtree(ctx, productCategoryID)
+	if err != nil {
+		return nil, translateError("product_category", err)
+	}
+
+	nodes := make(map[int64]*models.CategoryNode, len(productCategories))
+
+	// ordered by path, every parent is seen before its children
+	for _, productCategory := range productCategories {
+		node := &models.CategoryNode{
+			ID:       productCategory.ID,
+			Name:     productCategory.Name,
+			ParentID: productCategory.ParentID,
+			Children: []*models.CategoryNode{},
 		}
-		fmt.Println("ctxDetached after call:", ctx.Err())
-	}(product, productID)
+		nodes[node.ID] = node
 
-	return product, nil
+		if node.ID == productCategoryID || node.ParentID == nil {
+			continue
+		}
+
+		if parent, ok := nodes[*node.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	return nodes[productCategoryID], nil
+}
+
+// categorySubtreeIDs lists a category and its descendants, whose cached breadcrumbs all include it.
+// on error only the category itself is returned
+func (s *ProductService) categorySubtreeIDs(ctx context.Context, productCategoryID int64) []int64 {
+	productCategoryIDs, err := s.ProductRepository.FindCategorySubtreeIDs(ctx, productCategoryID)
+	if err != nil || len(productCategoryIDs) == 0 {
+		log.Logger.WithFields(logrus.Fields{
+			"productCategoryID": productCategoryID,
+		}).Errorf("s.ProductRepository.FindCategorySubtreeIDs got error %v", err)
+
+		return []int64{productCategoryID}
+	}
+
+	return productCategoryIDs
+}
+
+func (s *ProductService) GetProductCategoryByIDWithDeleted(ctx context.Context, productCategoryID int64) (*models.ProductCategory, error) {
+	productCategory, err := s.ProductRepository.FindProductCategoryByIDWithDeleted(ctx, productCategoryID)
+	if err != nil {
+		return nil, translateError("product_category", err)
+	}
+
+	return productCategory, nil
 }
 
 func (s *ProductService) GetProductCategoryByID(ctx context.Context, productCategoryID int64) (*models.ProductCategory, error) {
-	productCategory, err := s.ProductRepository.FindProductCategoryByID(ctx, productCategoryID)
+	productCategory, err := cacheAside(ctx, s.cacheGroup, s.Config.Cache, cacheSource[models.ProductCategory]{
+		entity:      "product_category",
+		ttl:         s.Config.Cache.ProductCategoryTTL,
+		getCache:    s.ProductRepository.GetProductCategoryByIDFromRedis,
+		generation:  s.ProductRepository.ProductCategoryCacheGeneration,
+		find:        s.ProductRepository.FindProductCategoryByID,
+		setCache:    s.ProductRepository.SetProductCategoryByID,
+		setNotFound: s.ProductRepository.SetProductCategoryNotFound,
+	}, productCategoryID)
 
 	if err != nil {
-		return nil, err
+		return nil, translateError("product_category", err)
 	}
 
 	return productCategory, nil
 }
 
 func (s *ProductService) CreateNewProduct(ctx context.Context, param *models.Product) (int64, error) {
-	productID, err := s.ProductRepository.InsertNewProduct(ctx, param)
+	if param.Currency == "" {
+		param.Currency = models.DefaultCurrency
+	}
 
+	err := joinFieldErrors(
+		checkPricePrecision("price", param.Price, param.Currency),
+		s.checkCategoryExists(ctx, "category_id", int64(param.CategoryID)),
+	)
 	if err != nil {
 		return 0, err
 	}
 
+	productID, err := s.ProductRepository.InsertNewProduct(ctx, param)
+
+	if err != nil {
+		return 0, translateError("product", err)
+	}
+
+	// drop a not found tombstone left by an earlier lookup of this id
+	s.ProductRepository.InvalidateProductCache(ctx, productID)
+
+	// a new product has no sale yet
+	param.ListPrice = param.Price
+
 	return productID, nil
 }
 
 func (s *ProductService) CreateNewProductCategory(ctx context.Context, param *models.ProductCategory) (int64, error) {
+	if param.ParentID != nil {
+		if err := s.checkCategoryExists(ctx, "parent_id", *param.ParentID); err != nil {
+			return 0, err
+		}
+	}
+
 	productCategoryID, err := s.ProductRepository.InsertNewProductCategory(ctx, param)
 
 	if err != nil {
-		return 0, err
+		return 0, translateError("product_category", err)
 	}
 
+	s.ProductRepository.InvalidateProductCategoryCache(ctx, productCategoryID)
+
 	return productCategoryID, nil
 }
 
+// UpdateProduct keeps the stored currency when the request leaves it out, as clients did before currencies existed
 func (s *ProductService) UpdateProduct(ctx context.Context, param *models.Product) (*models.Product, error) {
+	if param.Currency == "" {
+		currency, err := s.productCurrency(ctx, param.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		param.Currency = currency
+	}
+
+	err := joinFieldErrors(
+		checkPricePrecision("price", param.Price, param.Currency),
+		s.checkCategoryExists(ctx, "category_id", int64(param.CategoryID)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	product, err := s.ProductRepository.UpdateProduct(ctx, param)
 
 	if err != nil {
+		return nil, translateError("product", err)
+	}
+
+	s.ProductRepository.InvalidateProductCache(ctx, product.ID)
+
+	return withSale(product, time.Now()), nil
+}
+
+func (s *ProductService) PatchProduct(ctx context.Context, productID int64, patch *models.ProductPatch) (*models.Product, error) {
+	values := map[string]interface{}{}
+
+	if patch.Name != nil {
+		if *patch.Name == "" {
+			return nil, NewValidationError("invalid_name", "name must not be empty")
+		}
+
+		exists, err := s.ProductRepository.ExistsProductName(ctx, *patch.Name, productID)
+		if err != nil {
+			return nil, translateError("product", err)
+		}
+
+		if exists {
+			return nil, NewError(KindConflict, "product_name_taken", fmt.Sprintf("product name %q is already used", *patch.Name), nil)
+		}
+
+		values["name"] = *patch.Name
+	}
+
+	if patch.Description != nil {
+		values["description"] = *patch.Description
+	}
+
+	var priceErr, categoryErr error
+
+	if patch.Price != nil || patch.Currency != nil {
+		priceErr = s.checkPatchPrice(ctx, productID, patch)
+	}
+
+	if patch.Price != nil {
+		values["price"] = *patch.Price
+	}
+
+	if patch.Currency != nil {
+		values["currency"] = *patch.Currency
+	}
+
+	if patch.Stock != nil {
+		values["stock"] = *patch.Stock
+	}
+
+	if patch.CategoryID != nil {
+		categoryErr = s.checkCategoryExists(ctx, "category_id", int64(*patch.CategoryID))
+		values["category_id"] = *patch.CategoryID
+	}
+
+	if err := joinFieldErrors(priceErr, categoryErr); err != nil {
 		return nil, err
 	}
 
-	return product, nil
+	if len(values) == 0 {
+		return nil, NewValidationError("empty_patch", "request does not contain any field to update")
+	}
+
+	product, err := s.ProductRepository.PatchProduct(ctx, productID, patch.Version, values)
+
+	if err != nil {
+		return nil, translateError("product", err)
+	}
+
+	s.ProductRepository.InvalidateProductCache(ctx, product.ID)
+
+	return withSale(product, time.Now()), nil
+}
+
+// checkPatchPrice checks the price the product ends up with, a new currency may not fit the stored price
+func (s *ProductService) checkPatchPrice(ctx context.Context, productID int64, patch *models.ProductPatch) error {
+	if patch.Price != nil && patch.Currency != nil {
+		return checkPricePrecision("price", *patch.Price, *patch.Currency)
+	}
+
+	product, err := s.ProductRepository.FindProductByID(ctx, productID)
+	if err != nil {
+		return translateError("product", err)
+	}
+
+	price, currency := product.Price, product.Currency
+	if patch.Price != nil {
+		price = *patch.Price
+	}
+
+	if patch.Currency != nil {
+		currency = *patch.Currency
+	}
+
+	return checkPricePrecision("price", price, currency)
+}
+
+// checkCategoryExists answers 422 on field for a category that does not exist, a failed lookup is not
+// taken for a missing category and comes back as its own error, unavailable when the database is
+func (s *ProductService) checkCategoryExists(ctx context.Context, field string, productCategoryID int64) error {
+	_, err := s.GetProductCategoryByID(ctx, productCategoryID)
+
+	var domainErr *Error
+	if errors.As(err, &domainErr) && domainErr.Kind == KindNotFound {
+		return NewFieldValidationError([]FieldError{{
+			Field:   field,
+			Rule:    "category_exists",
+			Message: fmt.Sprintf("product category %d does not exist", productCategoryID),
+		}})
+	}
+
+	return err
 }
 
 func (s *ProductService) UpdateProductCategory(ctx context.Context, param *models.ProductCategory) (*models.ProductCategory, error) {
+	if param.ParentID != nil {
+		if err := s.checkCategoryExists(ctx, "parent_id", *param.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
 	productCategory, err := s.ProductRepository.UpdateProductCategory(ctx, param)
 
 	if err != nil {
-		return nil, err
+		return nil, translateError("product_category", err)
 	}
 
+	s.ProductRepository.InvalidateProductCategoryCache(ctx, s.categorySubtreeIDs(ctx, productCategory.ID)...)
+
 	return productCategory, nil
 }
 
+// ExportProducts hands every product matching the search filters to write, one row at a time in id order
+func (s *ProductService) ExportProducts(ctx context.Context, param *models.SearchProductParameter, write func(*models.Product) error) error {
+	if err := validateSearchFilters(param); err != nil {
+		return err
+	}
+
+	param.PricedAt = time.Now()
+	param.ListPrices = true
+
+	err := s.ProductRepository.StreamProducts(ctx, param, write)
+	if err != nil {
+		return translateError("product", err)
+	}
+
+	return nil
+}
+
+func validateSearchFilters(param *models.SearchProductParameter) error {
+	if param.MinPrice != nil && param.MaxPrice != nil && *param.MinPrice > *param.MaxPrice {
+		return NewValidationError("invalid_price_range", "min_price must not be greater than max_price")
+	}
+
+	if len(param.IDs) > maxSearchIDs || len(param.ExcludeIDs) > maxSearchIDs {
+		return NewValidationError("too_many_ids", fmt.Sprintf("ids and exclude_ids accept at most %d values", maxSearchIDs))
+	}
+
+	return nil
+}
+
 func (s *ProductService) DeleteProductByID(ctx context.Context, productID int64) error {
 	err := s.ProductRepository.DeleteProduct(ctx, productID)
 
 	if err != nil {
-		return err
+		return translateError("product", err)
 	}
 
+	s.ProductRepository.InvalidateProductCache(ctx, productID)
+
 	return nil
 }
 
-func (s *ProductService) DeleteProductCategoryByID(ctx context.Context, productCategoryID int64) error {
-	err := s.ProductRepository.DeleteProductCategory(ctx, productCategoryID)
+func (s *ProductService) DeleteProductCategoryByID(ctx context.Context, productCategoryID int64, param *models.DeleteProductCategoryParameter) error {
+	switch param.Mode {
+	case "", models.CategoryDeleteModeCascade:
+	case models.CategoryDeleteModeReassign:
+		if param.TargetCategoryID == 0 || param.TargetCategoryID == productCategoryID {
+			return NewValidationError("invalid_target_category", "reassign needs a target_category_id other than the deleted category")
+		}
+	default:
+		return NewValidationError("invalid_delete_mode", "mode must be reassign or cascade")
+	}
+
+	// collected first, a reassign moves the children out of the subtree
+	subtreeIDs := s.categorySubtreeIDs(ctx, productCategoryID)
 
+	productIDs, err := s.ProductRepository.DeleteProductCategory(ctx, productCategoryID, param, time.Now())
 	if err != nil {
-		return err
+		// a missing reassign target is the caller's mistake, not a missing category
+		if param.Mode == models.CategoryDeleteModeReassign && errors.Is(err, gorm.ErrRecordNotFound) {
+			if _, findErr := s.ProductRepository.FindProductCategoryByID(ctx, productCategoryID); findErr == nil {
+				return NewValidationError("invalid_target_category", "target category not found")
+			}
+		}
+
+		return translateError("product_category", err)
 	}
 
+	s.ProductRepository.InvalidateProductCategoryCache(ctx, subtreeIDs...)
+	s.ProductRepository.InvalidateProductCache(ctx, productIDs...)
+
 	return nil
 }
 
-func (s *ProductService) SearchProduct(ctx context.Context, param *models.SearchProductParameter) ([]models.Product, int, error) {
+func (s *ProductService) RestoreProduct(ctx context.Context, productID int64) (*models.Product, error) {
+	product, err := s.ProductRepository.RestoreProduct(ctx, productID)
+	if err != nil {
+		return nil, translateError("product", err)
+	}
+
+	// drop the not found tombstone left while it was deleted
+	s.ProductRepository.InvalidateProductCache(ctx, productID)
+
+	return withSale(product, time.Now()), nil
+}
+
+func (s *ProductService) RestoreProductCategory(ctx context.Context, productCategoryID int64) (*models.ProductCategory, error) {
+	productCategory, productIDs, err := s.ProductRepository.RestoreProductCategory(ctx, productCategoryID)
+	if err != nil {
+		return nil, translateError("product_category", err)
+	}
+
+	s.ProductRepository.InvalidateProductCategoryCache(ctx, s.categorySubtreeIDs(ctx, productCategoryID)...)
+	s.ProductRepository.InvalidateProductCache(ctx, productIDs...)
+
+	return productCategory, nil
+}
+
+func (s *ProductService) SearchProduct(ctx context.Context, param *models.SearchProductParameter) (*models.SearchProductResponse, error) {
+	if err := validateSearchFilters(param); err != nil {
+		return nil, err
+	}
+
+	if param.Cursor != "" {
+		cursor, err := decodeCursor(s.Config.Search.CursorSecret, param.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		if cursor.Sort != cursorSort(param) {
+			return nil, NewValidationError("invalid_cursor", "cursor was issued for a different sort order")
+		}
+
+		param.Keyset = cursor
+	}
+
+	if len(param.Facets) > 0 {
+		if err := prepareFacets(param, s.Config.Search.PriceBuckets); err != nil {
+			return nil, err
+		}
+	}
+
+	param.PricedAt = time.Now()
+
 	products, totalCount, err := s.ProductRepository.SearchProduct(ctx, param)
 	if err != nil {
-		return nil, 0, err
+		return nil, translateError("product", err)
+	}
+
+	// the repository reads one row past the page to tell whether there is more
+	hasMore := len(products) > param.PageSize
+	if hasMore {
+		products = products[:param.PageSize]
+	}
+
+	// a backward page is read in reverse order
+	backward := param.Keyset != nil && param.Keyset.Backward
+	if backward {
+		slices.Reverse(products)
+	}
+
+	response := &models.SearchProductResponse{
+		Products: products,
+		Page:     param.Page,
+		PageSize: param.PageSize,
+	}
+
+	if param.IncludeTotal {
+		totalPages := (totalCount + param.PageSize - 1) / param.PageSize
+		response.TotalCount = &totalCount
+		response.TotalPages = &totalPages
+	}
+
+	if len(param.Facets) > 0 {
+		rows, err := s.ProductRepository.SearchProductFacets(ctx, param)
+		if err != nil {
+			return nil, translateError("product", err)
+		}
+
+		response.Facets = buildSearchFacets(rows, param)
+	}
+
+	if len(products) == 0 {
+		return response, nil
+	}
+
+	// walking backward there is always a page after this one
+	if hasMore || backward {
+		nextCursor, err := encodeCursor(s.Config.Search.CursorSecret, newCursor(param, products[len(products)-1], false))
+		if err != nil {
+			return nil, translateError("product", err)
+		}
+
+		response.NextCursor = &nextCursor
+	}
+
+	if (backward && hasMore) || (!backward && (param.Keyset != nil || param.Page > 1)) {
+		prevCursor, err := encodeCursor(s.Config.Search.CursorSecret, newCursor(param, products[0], true))
+		if err != nil {
+			return nil, translateError("product", err)
+		}
+
+		response.PrevCursor = &prevCursor
+	}
+
+	return response, nil
+}
+
+// stock reservation
+func (s *ProductService) ReserveStock(ctx context.Context, productID int64, quantity int) (*models.StockReservation, error) {
+	now := time.Now()
+
+	reservation := &models.StockReservation{
+		ID:        uuid.New().String(),
+		ProductID: productID,
+		Quantity:  quantity,
+		Status:    models.ReservationStatusReserved,
+		ExpiresAt: now.Add(s.Config.Reservation.TTL),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := s.ProductRepository.ReserveStock(ctx, reservation)
+	if err != nil {
+		return nil, translateError("product", err)
+	}
+
+	s.ProductRepository.InvalidateProductCache(ctx, productID)
+
+	return reservation, nil
+}
+
+func (s *ProductService) CommitReservation(ctx context.Context, reservationID string) (*models.StockReservation, error) {
+	reservation, err := s.ProductRepository.CommitReservation(ctx, reservationID, time.Now())
+	if err != nil {
+		return nil, translateError("reservation", err)
+	}
+
+	return reservation, nil
+}
+
+func (s *ProductService) ReleaseReservation(ctx context.Context, reservationID string) (*models.StockReservation, error) {
+	reservation, err := s.ProductRepository.ReleaseReservation(ctx, reservationID, time.Now())
+	if err != nil {
+		return nil, translateError("reservation", err)
+	}
+
+	s.ProductRepository.InvalidateProductCache(ctx, reservation.ProductID)
+
+	return reservation, nil
+}
+
+// sweeper, returns expired reservation stock back to the product until ctx is done
+func (s *ProductService) RunReservationSweeper(ctx context.Context) {
+	ticker := time.NewTicker(s.Config.Reservation.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sweepCtx, cancel := context.WithTimeout(ctx, s.Config.Reservation.SweepInterval)
+			expired, err := s.ProductRepository.ExpireReservations(sweepCtx, time.Now(), 100)
+
+			if err != nil {
+				cancel()
+				log.Logger.Errorf("s.ProductRepository.ExpireReservations got error %v", err)
+				continue
+			}
+
+			for _, reservation := range expired {
+				s.ProductRepository.InvalidateProductCache(sweepCtx, reservation.ProductID)
+			}
+			cancel()
+
+			if len(expired) > 0 {
+				log.Logger.WithFields(logrus.Fields{
+					"expired": len(expired),
+				}).Info("Expired stock reservations released.")
+			}
+		}
+	}
+}
+
+// stock adjustment
+func (s *ProductService) AdjustStock(ctx context.Context, productID int64, param *models.StockAdjustmentParameter) (*models.InventoryMovement, error) {
+	movement, err := s.ProductRepository.AdjustStock(ctx, productID, param.Delta, param.Reason, param.Note)
+	if err != nil {
+		return nil, translateError("product", err)
+	}
+
+	s.ProductRepository.InvalidateProductCache(ctx, productID)
+
+	return movement, nil
+}
+
+func (s *ProductService) GetInventoryMovements(ctx context.Context, productID int64, page int, pageSize int) ([]models.InventoryMovement, int, error) {
+	movements, totalCount, err := s.ProductRepository.FindInventoryMovements(ctx, productID, page, pageSize)
+	if err != nil {
+		return nil, 0, translateError("product", err)
 	}
 
-	return products, totalCount, nil
+	return movements, totalCount, nil
 }
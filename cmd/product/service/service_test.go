@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"product/models"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCheckCategoryExists(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("found", func(t *testing.T) {
+		env := newTestEnv(t)
+		env.mock.ExpectQuery(`FROM "product_category"`).WillReturnRows(categoryRows(7))
+
+		if err := env.service.checkCategoryExists(ctx, "category_id", 7); err != nil {
+			t.Fatal(err)
+		}
+
+		env.checkExpectations(t)
+	})
+
+	t.Run("missing category is a field error", func(t *testing.T) {
+		env := newTestEnv(t)
+		env.mock.ExpectQuery(`FROM "product_category"`).WillReturnRows(categoryRows())
+
+		err := env.service.checkCategoryExists(ctx, "parent_id", 7)
+
+		var serviceErr *Error
+		if !errors.As(err, &serviceErr) || serviceErr.Kind != KindUnprocessable {
+			t.Fatalf("got error %v, want unprocessable", err)
+		}
+
+		if len(serviceErr.Fields) != 1 || serviceErr.Fields[0].Field != "parent_id" || serviceErr.Fields[0].Rule != "category_exists" {
+			t.Fatalf("got fields %+v, want parent_id failing category_exists", serviceErr.Fields)
+		}
+
+		env.checkExpectations(t)
+	})
+
+	t.Run("failed lookup is not a missing category", func(t *testing.T) {
+		env := newTestEnv(t)
+		env.mock.ExpectQuery(`FROM "product_category"`).WillReturnError(&net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")})
+
+		err := env.service.checkCategoryExists(ctx, "category_id", 7)
+
+		var serviceErr *Error
+		if !errors.As(err, &serviceErr) || serviceErr.Kind != KindUnavailable {
+			t.Fatalf("got error %v, want unavailable", err)
+		}
+
+		if env.redis.Exists("product_category:7") {
+			t.Fatal("a failed lookup cached the category as missing")
+		}
+
+		env.checkExpectations(t)
+	})
+}
+
+// an edit that changes stock bumps the version once, the ledger update runs inside the versioned update
+func TestUpdateProductStockBumpsVersionOnce(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	before := testProduct()
+	after := before
+	after.Stock = 8
+	after.Version = 2
+
+	env.mock.ExpectBegin()
+	env.expectFindProduct(&before)
+	env.mock.ExpectExec(`UPDATE "product" SET .*"version"=version \+ 1`).WillReturnResult(sqlmock.NewResult(0, 1))
+	env.mock.ExpectQuery(`SELECT stock FROM product`).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(before.Stock))
+	env.mock.ExpectQuery(`^UPDATE product SET stock = stock \+ \$1 WHERE`).WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(after.Stock))
+	env.mock.ExpectQuery(`INSERT INTO "inventory_movement"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	env.expectFindProduct(&after)
+	env.mock.ExpectQuery(`INSERT INTO "product_history"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	env.mock.ExpectCommit()
+	env.expectFindProduct(&after)
+
+	product, err := env.service.UpdateProduct(ctx, &models.Product{
+		ID:         before.ID,
+		Name:       before.Name,
+		Price:      before.Price,
+		Currency:   before.Currency,
+		Stock:      after.Stock,
+		CategoryID: before.CategoryID,
+		Version:    before.Version,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if product.Stock != after.Stock || product.Version != before.Version+1 {
+		t.Fatalf("got stock %d version %d, want %d and %d", product.Stock, product.Version, after.Stock, before.Version+1)
+	}
+
+	env.checkExpectations(t)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"product/models"
+
+	"gorm.io/gorm"
+)
+
+// variants are cached as part of their product, every write drops the product from the cache
+
+func (s *ProductService) GetProductVariants(ctx context.Context, productID int64) ([]models.ProductVariant, error) {
+	product, err := s.cachedProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	variants := make([]models.ProductVariant, len(product.Variants))
+	copy(variants, product.Variants)
+
+	return variants, nil
+}
+
+func (s *ProductService) GetProductVariantByID(ctx context.Context, productID int64, variantID int64) (*models.ProductVariant, error) {
+	product, err := s.cachedProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, variant := range product.Variants {
+		if variant.ID == variantID {
+			return &variant, nil
+		}
+	}
+
+	return nil, translateError("product_variant", gorm.ErrRecordNotFound)
+}
+
+func (s *ProductService) CreateProductVariant(ctx context.Context, param *models.ProductVariant) (int64, error) {
+	// a missing product is reported as such rather than as a missing variant, it also gives the currency
+	product, err := s.cachedProduct(ctx, param.ProductID)
+	if err != nil {
+		return 0, err
+	}
+
+	if param.Price != nil {
+		if err := checkPricePrecision("price", *param.Price, product.Currency); err != nil {
+			return 0, err
+		}
+	}
+
+	normalizeVariant(param)
+
+	variantID, err := s.ProductRepository.InsertProductVariant(ctx, param)
+	if err != nil {
+		return 0, translateError("product_variant", err)
+	}
+
+	s.ProductRepository.InvalidateProductCache(ctx, param.ProductID)
+
+	return variantID, nil
+}
+
+func (s *ProductService) UpdateProductVariant(ctx context.Context, param *models.ProductVariant) (*models.ProductVariant, error) {
+	product, err := s.cachedProduct(ctx, param.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	if param.Price != nil {
+		if err := checkPricePrecision("price", *param.Price, product.Currency); err != nil {
+			return nil, err
+		}
+	}
+
+	normalizeVariant(param)
+
+	variant, err := s.ProductRepository.UpdateProductVariant(ctx, param)
+	if err != nil {
+		return nil, translateError("product_variant", err)
+	}
+
+	s.ProductRepository.InvalidateProductCache(ctx, param.ProductID)
+
+	return variant, nil
+}
+
+func (s *ProductService) DeleteProductVariant(ctx context.Context, productID int64, variantID int64) error {
+	_, err := s.cachedProduct(ctx, productID)
+	if err != nil {
+		return err
+	}
+
+	err = s.ProductRepository.DeleteProductVariant(ctx, productID, variantID)
+	if err != nil {
+		return translateError("product_variant", err)
+	}
+
+	s.ProductRepository.InvalidateProductCache(ctx, productID)
+
+	return nil
+}
+
+// normalizeVariant stores missing options as an empty object, the unique (product_id, options) index
+// then keeps a product from having two variants without options
+func normalizeVariant(variant *models.ProductVariant) {
+	if variant.Options == nil {
+		variant.Options = map[string]string{}
+	}
+}
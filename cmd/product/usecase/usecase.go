@@ -5,6 +5,7 @@ import (
 	"product/cmd/product/service"
 	"product/infrastructure/log"
 	"product/models"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -19,8 +20,8 @@ func NewProductUsecase(productService service.ProductService) *ProductUsecase {
 	}
 }
 
-func (uc *ProductUsecase) GetProductByID(ctx context.Context, productID int64) (*models.Product, error) {
-	product, err := uc.ProductService.GetProductByID(ctx, productID)
+func (uc *ProductUsecase) GetProductByID(ctx context.Context, productID int64, currency string) (*models.Product, error) {
+	product, err := uc.ProductService.GetProductByID(ctx, productID, currency)
 
 	if err != nil {
 		return nil, err
@@ -29,6 +30,28 @@ func (uc *ProductUsecase) GetProductByID(ctx context.Context, productID int64) (
 	return product, nil
 }
 
+func (uc *ProductUsecase) BatchGetProducts(ctx context.Context, productIDs []int64) ([]models.BatchGetProductResult, error) {
+	results, err := uc.ProductService.BatchGetProducts(ctx, productIDs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (uc *ProductUsecase) GetProductByIDWithDeleted(ctx context.Context, productID int64, currency string) (*models.Product, error) {
+	return uc.ProductService.GetProductByIDWithDeleted(ctx, productID, currency)
+}
+
+func (uc *ProductUsecase) GetProductCategoryByIDWithDeleted(ctx context.Context, productCategoryID int64) (*models.ProductCategory, error) {
+	return uc.ProductService.GetProductCategoryByIDWithDeleted(ctx, productCategoryID)
+}
+
+func (uc *ProductUsecase) GetProductCategoryTree(ctx context.Context, productCategoryID int64) (*models.CategoryNode, error) {
+	return uc.ProductService.GetProductCategoryTree(ctx, productCategoryID)
+}
+
 func (uc *ProductUsecase) GetProductCategoryByID(ctx context.Context, productCategoryID int64) (*models.ProductCategory, error) {
 	productCategory, err := uc.ProductService.GetProductCategoryByID(ctx, productCategoryID)
 
@@ -54,6 +77,21 @@ func (uc *ProductUsecase) CreateNewProduct(ctx context.Context, param *models.Pr
 	return productID, nil
 }
 
+func (uc *ProductUsecase) ImportProducts(ctx context.Context, param *models.ProductImportParameter) (*models.ProductImportReport, error) {
+	report, err := uc.ProductService.ImportProducts(ctx, param)
+
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"rows":   len(param.Rows),
+			"dryRun": param.DryRun,
+		}).Errorf("uc.ProductService.ImportProducts got error %v", err)
+
+		return nil, err
+	}
+
+	return report, nil
+}
+
 func (uc *ProductUsecase) CreateNewProductCategory(ctx context.Context, param *models.ProductCategory) (int64, error) {
 	productCategoryID, err := uc.ProductService.CreateNewProductCategory(ctx, param)
 
@@ -78,6 +116,16 @@ func (uc *ProductUsecase) EditProduct(ctx context.Context, param *models.Product
 	return product, nil
 }
 
+func (uc *ProductUsecase) PatchProduct(ctx context.Context, productID int64, patch *models.ProductPatch) (*models.Product, error) {
+	product, err := uc.ProductService.PatchProduct(ctx, productID, patch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
 func (uc *ProductUsecase) EditProductCategory(ctx context.Context, param *models.ProductCategory) (*models.ProductCategory, error) {
 	productCategory, err := uc.ProductService.UpdateProductCategory(ctx, param)
 
@@ -93,16 +141,148 @@ func (uc *ProductUsecase) DeleteProduct(ctx context.Context, productID int64) er
 	return uc.ProductService.DeleteProductByID(ctx, productID)
 }
 
-func (uc *ProductUsecase) DeleteProductCategory(ctx context.Context, productCategoryID int64) error {
-	return uc.ProductService.DeleteProductCategoryByID(ctx, productCategoryID)
+func (uc *ProductUsecase) RestoreProduct(ctx context.Context, productID int64) (*models.Product, error) {
+	return uc.ProductService.RestoreProduct(ctx, productID)
+}
+
+func (uc *ProductUsecase) RestoreProductCategory(ctx context.Context, productCategoryID int64) (*models.ProductCategory, error) {
+	return uc.ProductService.RestoreProductCategory(ctx, productCategoryID)
+}
+
+func (uc *ProductUsecase) DeleteProductCategory(ctx context.Context, productCategoryID int64, param *models.DeleteProductCategoryParameter) error {
+	return uc.ProductService.DeleteProductCategoryByID(ctx, productCategoryID, param)
 }
 
 // search
-func (s *ProductUsecase) SearchProduct(ctx context.Context, param *models.SearchProductParameter) ([]models.Product, int, error) {
-	products, totalCount, err := s.ProductService.SearchProduct(ctx, param)
+func (s *ProductUsecase) SearchProduct(ctx context.Context, param *models.SearchProductParameter) (*models.SearchProductResponse, error) {
+	response, err := s.ProductService.SearchProduct(ctx, param)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
-	return products, totalCount, nil
+	return response, nil
+}
+
+func (uc *ProductUsecase) ExportProducts(ctx context.Context, param *models.SearchProductParameter, write func(*models.Product) error) error {
+	return uc.ProductService.ExportProducts(ctx, param, write)
+}
+
+// stock reservation
+func (uc *ProductUsecase) ReserveStock(ctx context.Context, productID int64, quantity int) (*models.StockReservation, error) {
+	reservation, err := uc.ProductService.ReserveStock(ctx, productID, quantity)
+
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+			"quantity":  quantity,
+		}).Errorf("uc.ProductService.ReserveStock got error %v", err)
+
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+func (uc *ProductUsecase) CommitReservation(ctx context.Context, reservationID string) (*models.StockReservation, error) {
+	return uc.ProductService.CommitReservation(ctx, reservationID)
+}
+
+func (uc *ProductUsecase) ReleaseReservation(ctx context.Context, reservationID string) (*models.StockReservation, error) {
+	return uc.ProductService.ReleaseReservation(ctx, reservationID)
+}
+
+// stock adjustment
+func (uc *ProductUsecase) AdjustStock(ctx context.Context, productID int64, param *models.StockAdjustmentParameter) (*models.InventoryMovement, error) {
+	movement, err := uc.ProductService.AdjustStock(ctx, productID, param)
+
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": productID,
+			"delta":     param.Delta,
+			"reason":    param.Reason,
+		}).Errorf("uc.ProductService.AdjustStock got error %v", err)
+
+		return nil, err
+	}
+
+	return movement, nil
+}
+
+func (uc *ProductUsecase) GetInventoryMovements(ctx context.Context, productID int64, page int, pageSize int) ([]models.InventoryMovement, int, error) {
+	return uc.ProductService.GetInventoryMovements(ctx, productID, page, pageSize)
+}
+
+// product history
+func (uc *ProductUsecase) GetProductHistory(ctx context.Context, productID int64, page int, pageSize int) ([]models.ProductHistory, int, error) {
+	return uc.ProductService.GetProductHistory(ctx, productID, page, pageSize)
+}
+
+func (uc *ProductUsecase) GetProductAsOf(ctx context.Context, productID int64, currency string, asOf time.Time, withDeleted bool) (*models.Product, error) {
+	return uc.ProductService.GetProductAsOf(ctx, productID, currency, asOf, withDeleted)
+}
+
+// product variants
+func (uc *ProductUsecase) GetProductVariants(ctx context.Context, productID int64) ([]models.ProductVariant, error) {
+	return uc.ProductService.GetProductVariants(ctx, productID)
+}
+
+func (uc *ProductUsecase) GetProductVariantByID(ctx context.Context, productID int64, variantID int64) (*models.ProductVariant, error) {
+	return uc.ProductService.GetProductVariantByID(ctx, productID, variantID)
+}
+
+func (uc *ProductUsecase) CreateProductVariant(ctx context.Context, param *models.ProductVariant) (int64, error) {
+	variantID, err := uc.ProductService.CreateProductVariant(ctx, param)
+
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": param.ProductID,
+			"sku":       param.SKU,
+		}).Errorf("uc.ProductService.CreateProductVariant got error %v", err)
+
+		return 0, err
+	}
+
+	return variantID, nil
+}
+
+func (uc *ProductUsecase) UpdateProductVariant(ctx context.Context, param *models.ProductVariant) (*models.ProductVariant, error) {
+	return uc.ProductService.UpdateProductVariant(ctx, param)
+}
+
+func (uc *ProductUsecase) DeleteProductVariant(ctx context.Context, productID int64, variantID int64) error {
+	return uc.ProductService.DeleteProductVariant(ctx, productID, variantID)
+}
+
+// price lists
+func (uc *ProductUsecase) SetProductPrice(ctx context.Context, param *models.ProductPrice) (*models.ProductPrice, error) {
+	return uc.ProductService.SetProductPrice(ctx, param)
+}
+
+func (uc *ProductUsecase) DeleteProductPrice(ctx context.Context, productID int64, currency string) error {
+	return uc.ProductService.DeleteProductPrice(ctx, productID, currency)
+}
+
+// price schedules
+func (uc *ProductUsecase) GetPriceSchedules(ctx context.Context, productID int64) ([]models.PriceSchedule, error) {
+	return uc.ProductService.GetPriceSchedules(ctx, productID)
+}
+
+func (uc *ProductUsecase) CreatePriceSchedule(ctx context.Context, param *models.PriceSchedule) (int64, error) {
+	scheduleID, err := uc.ProductService.CreatePriceSchedule(ctx, param)
+
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"productID": param.ProductID,
+			"startsAt":  param.StartsAt,
+			"endsAt":    param.EndsAt,
+		}).Errorf("uc.ProductService.CreatePriceSchedule got error %v", err)
+
+		return 0, err
+	}
+
+	return scheduleID, nil
+}
+
+func (uc *ProductUsecase) DeletePriceSchedule(ctx context.Context, productID int64, scheduleID int64) error {
+	return uc.ProductService.DeletePriceSchedule(ctx, productID, scheduleID)
 }
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 
 	"github.com/spf13/viper"
@@ -13,6 +15,19 @@ func LoadConfig() Config {
 	viper.SetConfigName(".env")
 	viper.SetConfigType("env")
 	viper.AutomaticEnv()
+
+	// defaults
+	viper.SetDefault("DB_AUTO_MIGRATE", false)
+	viper.SetDefault("STOCK_RESERVATION_TTL", "15m")
+	viper.SetDefault("STOCK_RESERVATION_SWEEP_INTERVAL", "30s")
+	viper.SetDefault("CACHE_PRODUCT_TTL", "5m")
+	viper.SetDefault("CACHE_PRODUCT_CATEGORY_TTL", "1m")
+	viper.SetDefault("CACHE_NOT_FOUND_TTL", "30s")
+	viper.SetDefault("CACHE_TTL_JITTER", "30s")
+	viper.SetDefault("SEARCH_PRICE_BUCKETS", "0,50,100,500,1000")
+	viper.SetDefault("PRICE_SCHEDULE_CHECK_INTERVAL", "1m")
+	viper.SetDefault("IMPORT_TIMEOUT", "5m")
+
 	err := viper.ReadInConfig()
 
 	if err != nil {
@@ -31,5 +46,40 @@ func LoadConfig() Config {
 		log.Fatalf("error unmarshal redis config: %s", err)
 	}
 
+	if err := viper.Unmarshal(&cfg.Admin); err != nil {
+		log.Fatalf("error unmarshal admin config: %s", err)
+	}
+
+	if err := viper.Unmarshal(&cfg.Reservation); err != nil {
+		log.Fatalf("error unmarshal reservation config: %s", err)
+	}
+
+	if err := viper.Unmarshal(&cfg.Cache); err != nil {
+		log.Fatalf("error unmarshal cache config: %s", err)
+	}
+
+	if err := viper.Unmarshal(&cfg.Search); err != nil {
+		log.Fatalf("error unmarshal search config: %s", err)
+	}
+
+	if err := viper.Unmarshal(&cfg.Pricing); err != nil {
+		log.Fatalf("error unmarshal pricing config: %s", err)
+	}
+
+	if err := viper.Unmarshal(&cfg.Import); err != nil {
+		log.Fatalf("error unmarshal import config: %s", err)
+	}
+
+	// cursors signed with a random secret stop working on restart and across instances
+	if cfg.Search.CursorSecret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("error generate search cursor secret: %s", err)
+		}
+
+		cfg.Search.CursorSecret = hex.EncodeToString(secret)
+		log.Println("SEARCH_CURSOR_SECRET is not set, using a random secret")
+	}
+
 	return cfg
 }
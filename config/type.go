@@ -1,10 +1,18 @@
 package config
 
+import "time"
+
 type Config struct {
-	App      AppConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	Jwt      JwtConfig
+	App         AppConfig
+	Database    DatabaseConfig
+	Redis       RedisConfig
+	Jwt         JwtConfig
+	Admin       AdminConfig
+	Reservation ReservationConfig
+	Cache       CacheConfig
+	Search      SearchConfig
+	Pricing     PricingConfig
+	Import      ImportConfig
 }
 
 type AppConfig struct {
@@ -18,6 +26,9 @@ type DatabaseConfig struct {
 	Password string `mapstructure:"DB_PASSWORD"`
 	Name     string `mapstructure:"DB_NAME"`
 	Port     string `mapstructure:"DB_PORT"`
+
+	// apply pending migrations before the server starts
+	AutoMigrate bool `mapstructure:"DB_AUTO_MIGRATE"`
 }
 
 type RedisConfig struct {
@@ -26,6 +37,38 @@ type RedisConfig struct {
 	Port     string `mapstructure:"REDIS_PORT"`
 }
 
+// AdminConfig holds the token callers send as X-Admin-Token to unlock admin only parameters, empty disables them
+type AdminConfig struct {
+	Token string `mapstructure:"ADMIN_API_TOKEN"`
+}
+
 type JwtConfig struct {
 	Secret string `mapstructure:"JWT_SECRET_KEY"`
 }
+
+type ReservationConfig struct {
+	TTL           time.Duration `mapstructure:"STOCK_RESERVATION_TTL"`
+	SweepInterval time.Duration `mapstructure:"STOCK_RESERVATION_SWEEP_INTERVAL"`
+}
+
+type SearchConfig struct {
+	CursorSecret string    `mapstructure:"SEARCH_CURSOR_SECRET"`
+	PriceBuckets []float64 `mapstructure:"SEARCH_PRICE_BUCKETS"`
+}
+
+// PricingConfig caps how long the sale scheduler sleeps before it looks for newly created schedules
+type PricingConfig struct {
+	ScheduleCheckInterval time.Duration `mapstructure:"PRICE_SCHEDULE_CHECK_INTERVAL"`
+}
+
+// ImportConfig bounds a whole bulk import request, it replaces the default request timeout on that route
+type ImportConfig struct {
+	Timeout time.Duration `mapstructure:"IMPORT_TIMEOUT"`
+}
+
+type CacheConfig struct {
+	ProductTTL         time.Duration `mapstructure:"CACHE_PRODUCT_TTL"`
+	ProductCategoryTTL time.Duration `mapstructure:"CACHE_PRODUCT_CATEGORY_TTL"`
+	NotFoundTTL        time.Duration `mapstructure:"CACHE_NOT_FOUND_TTL"`
+	TTLJitter          time.Duration `mapstructure:"CACHE_TTL_JITTER"`
+}
@@ -1,4 +0,0 @@
-CREATE TABLE product_category {
-    id SERIAL PRIMARY KEY,
-    name varchar(255) UNIQUE NOT NULL
-}
\ No newline at end of file
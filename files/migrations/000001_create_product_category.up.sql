@@ -0,0 +1,5 @@
+CREATE TABLE product_category (
+    id SERIAL PRIMARY KEY,
+    name varchar(255) UNIQUE NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
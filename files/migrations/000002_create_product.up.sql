@@ -0,0 +1,19 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE product (
+    id BIGSERIAL PRIMARY KEY,
+    name varchar(255) UNIQUE NOT NULL,
+    description text,
+    price numeric NOT NULL,
+    stock integer NOT NULL,
+    category_id integer NOT NULL,
+    version integer NOT NULL DEFAULT 1,
+    search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+        setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+    ) STORED,
+    CONSTRAINT fk_category FOREIGN KEY (category_id) REFERENCES product_category(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_product_search_vector ON product USING GIN (search_vector);
+CREATE INDEX idx_product_name_trgm ON product USING GIN (name gin_trgm_ops);
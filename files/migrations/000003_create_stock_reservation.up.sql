@@ -0,0 +1,12 @@
+CREATE TABLE stock_reservation (
+    id uuid PRIMARY KEY,
+    product_id bigint NOT NULL,
+    quantity integer NOT NULL CHECK (quantity > 0),
+    status varchar(20) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT fk_product FOREIGN KEY (product_id) REFERENCES product(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_stock_reservation_status_expires_at ON stock_reservation (status, expires_at);
@@ -0,0 +1,13 @@
+CREATE TABLE inventory_movement (
+    id BIGSERIAL PRIMARY KEY,
+    product_id bigint NOT NULL,
+    delta integer NOT NULL,
+    stock_after integer NOT NULL,
+    reason varchar(20) NOT NULL,
+    reference_id varchar(64),
+    note text,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT fk_product FOREIGN KEY (product_id) REFERENCES product(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_inventory_movement_product_id ON inventory_movement (product_id, id DESC);
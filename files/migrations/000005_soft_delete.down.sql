@@ -0,0 +1,7 @@
+ALTER TABLE product DROP CONSTRAINT fk_category;
+ALTER TABLE product ADD CONSTRAINT fk_category FOREIGN KEY (category_id) REFERENCES product_category(id) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS idx_product_category_id_live;
+
+ALTER TABLE product DROP COLUMN deleted_at;
+ALTER TABLE product_category DROP COLUMN deleted_at;
@@ -0,0 +1,8 @@
+ALTER TABLE product_category ADD COLUMN deleted_at timestamptz;
+ALTER TABLE product ADD COLUMN deleted_at timestamptz;
+
+CREATE INDEX idx_product_category_id_live ON product (category_id) WHERE deleted_at IS NULL;
+
+-- rows are only soft deleted now, a hard delete of a category must not take its products along
+ALTER TABLE product DROP CONSTRAINT fk_category;
+ALTER TABLE product ADD CONSTRAINT fk_category FOREIGN KEY (category_id) REFERENCES product_category(id) ON DELETE RESTRICT;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_product_category_path;
+DROP INDEX IF EXISTS idx_product_category_parent_id;
+
+ALTER TABLE product_category DROP COLUMN path;
+ALTER TABLE product_category DROP COLUMN parent_id;
@@ -0,0 +1,8 @@
+ALTER TABLE product_category ADD COLUMN parent_id integer REFERENCES product_category(id) ON DELETE RESTRICT;
+
+-- materialized path of ancestor ids ending with the category itself, such as /1/4/9/
+ALTER TABLE product_category ADD COLUMN path text NOT NULL DEFAULT '';
+UPDATE product_category SET path = '/' || id || '/';
+
+CREATE INDEX idx_product_category_parent_id ON product_category (parent_id);
+CREATE INDEX idx_product_category_path ON product_category (path text_pattern_ops);
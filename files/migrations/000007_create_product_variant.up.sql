@@ -0,0 +1,13 @@
+CREATE TABLE product_variant (
+    id BIGSERIAL PRIMARY KEY,
+    product_id bigint NOT NULL,
+    sku varchar(64) UNIQUE NOT NULL,
+    -- option attributes such as {"size": "M", "color": "red"}
+    options jsonb NOT NULL DEFAULT '{}',
+    -- NULL falls back to the product price
+    price numeric,
+    stock integer NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT fk_product FOREIGN KEY (product_id) REFERENCES product(id) ON DELETE CASCADE,
+    CONSTRAINT uq_product_variant_options UNIQUE (product_id, options)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS product_price;
+
+ALTER TABLE product DROP COLUMN currency;
@@ -0,0 +1,13 @@
+-- prices are exact numerics in the product's ISO 4217 currency, existing products were priced in USD
+ALTER TABLE product ADD COLUMN currency varchar(3) NOT NULL DEFAULT 'USD';
+
+-- optional price lists, the price of a product in other currencies
+CREATE TABLE product_price (
+    product_id bigint NOT NULL,
+    currency varchar(3) NOT NULL,
+    price numeric NOT NULL CHECK (price >= 0),
+    PRIMARY KEY (product_id, currency),
+    CONSTRAINT fk_product FOREIGN KEY (product_id) REFERENCES product(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_product_price_currency ON product_price (currency, product_id);
@@ -0,0 +1,14 @@
+-- time-boxed sale prices in the product's own currency, the repository keeps them from overlapping per product
+CREATE TABLE product_price_schedule (
+    id BIGSERIAL PRIMARY KEY,
+    product_id bigint NOT NULL,
+    sale_price numeric NOT NULL CHECK (sale_price >= 0),
+    starts_at timestamptz NOT NULL,
+    ends_at timestamptz NOT NULL,
+    CONSTRAINT fk_product FOREIGN KEY (product_id) REFERENCES product(id) ON DELETE CASCADE,
+    CONSTRAINT chk_product_price_schedule_period CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX idx_product_price_schedule_product_id ON product_price_schedule (product_id, starts_at);
+CREATE INDEX idx_product_price_schedule_starts_at ON product_price_schedule (starts_at);
+CREATE INDEX idx_product_price_schedule_ends_at ON product_price_schedule (ends_at);
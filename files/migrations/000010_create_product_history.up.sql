@@ -0,0 +1,16 @@
+-- every change to a product, before and after hold the product with its variants, price list and sales
+-- as read inside the changing transaction, before is NULL on create
+CREATE TABLE product_history (
+    id BIGSERIAL PRIMARY KEY,
+    product_id bigint NOT NULL,
+    action varchar(16) NOT NULL,
+    changes jsonb NOT NULL DEFAULT '[]',
+    actor varchar(255) NOT NULL,
+    request_id varchar(64) NOT NULL DEFAULT '',
+    before jsonb,
+    after jsonb NOT NULL,
+    changed_at timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT fk_product FOREIGN KEY (product_id) REFERENCES product(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_product_history_product_id ON product_history (product_id, changed_at);
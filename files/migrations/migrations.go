@@ -0,0 +1,8 @@
+// Package migrations holds the numbered schema migrations, NNNNNN_name.up.sql applies one and
+// NNNNNN_name.down.sql reverts it. files are embedded into the binary and applied by infrastructure/migrate
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
+	"os"
 	"product/cmd/product/handler"
 	"product/cmd/product/repository"
 	"product/cmd/product/resource"
 	"product/cmd/product/service"
 	"product/cmd/product/usecase"
 	"product/config"
+	"product/files/migrations"
 	"product/infrastructure/log"
+	"product/infrastructure/migrate"
 	"product/routes"
 
 	"github.com/gin-gonic/gin"
@@ -16,22 +20,55 @@ import (
 func main() {
 	// init config
 	cfg := config.LoadConfig()
-	redis := resource.InitRedis(&cfg)
 	db := resource.InitDb(&cfg)
 
 	// logger
 	log.SetupLogger()
 
+	// migrations, "product migrate up|down|status" runs them and exits without touching redis
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		log.Logger.Fatalf("migrate.New got error %v", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrator.Run(context.Background(), os.Args[2:], os.Stdout); err != nil {
+			log.Logger.Fatalf("migrate got error %v", err)
+		}
+
+		return
+	}
+
+	if cfg.Database.AutoMigrate {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Logger.Fatalf("migrator.Up got error %v", err)
+		}
+
+		log.Logger.Infof("applied %d migrations", len(applied))
+	}
+
+	redis := resource.InitRedis(&cfg)
+
 	// init
 	productRepository := repository.NewProductRepository(redis, db)
-	productService := service.NewProductService(*productRepository)
+	productService := service.NewProductService(*productRepository, &cfg)
 	productUsecase := usecase.NewProductUsecase(*productService)
 	productHandler := handler.NewProductHandler(*productUsecase)
 
+	if err := productHandler.RegisterValidators(); err != nil {
+		log.Logger.Fatalf("productHandler.RegisterValidators got error %v", err)
+	}
+
+	// background worker
+	go productService.RunReservationSweeper(context.Background())
+	go productService.RunPriceScheduler(context.Background())
+	go productRepository.RunCacheInvalidationWorker(context.Background())
+
 	// gin
 	port := cfg.App.Port
 	router := gin.Default()
-	routes.SetupRoutes(router, *productHandler)
+	routes.SetupRoutes(router, *productHandler, &cfg)
 	router.Run(":" + port)
 
 	log.Logger.Printf("Server running on port: %s", port)
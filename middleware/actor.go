@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"product/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActorHeader names who makes a change, it ends up in the product history
+const ActorHeader = "X-Actor"
+
+// maxActorLength matches product_history.actor
+const maxActorLength = 255
+
+// Actor puts the caller named by X-Actor on the request context. the header is only trusted together with
+// the admin token, admin token holders without one are recorded as admin and everyone else as anonymous.
+// it has to run after AdminToken and after RequestLogger, which replaces the request context
+func Actor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool(IsAdminKey) {
+			c.Next()
+
+			return
+		}
+
+		actor := strings.TrimSpace(c.GetHeader(ActorHeader))
+		if actor == "" {
+			actor = "admin"
+		}
+
+		if runes := []rune(actor); len(runes) > maxActorLength {
+			actor = string(runes[:maxActorLength])
+		}
+
+		c.Request = c.Request.WithContext(models.ContextWithActor(c.Request.Context(), actor))
+
+		c.Next()
+	}
+}
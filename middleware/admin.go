@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IsAdminKey is set on the context when the request carries the admin token
+const IsAdminKey = "is_admin"
+
+// AdminToken marks requests whose X-Admin-Token header matches token, it never rejects a request by itself.
+// an empty token leaves every request unprivileged
+func AdminToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("X-Admin-Token")
+		if token != "" && subtle.ConstantTimeCompare([]byte(header), []byte(token)) == 1 {
+			c.Set(IsAdminKey, true)
+		}
+
+		c.Next()
+	}
+}
@@ -10,15 +10,33 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func RequestLogger(timeout time.Duration) gin.HandlerFunc {
+// RouteTimeouts overrides the request deadline of long running routes, keyed by method and path as registered
+// such as "POST /v1/products/import". zero leaves the route without a deadline, it still ends when the client goes away
+type RouteTimeouts map[string]time.Duration
+
+func RequestLogger(timeout time.Duration, routeTimeouts RouteTimeouts) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestID := uuid.New().String()
 
-		timeoutCtx, cancel := context.WithTimeout(context.Background(), timeout*time.Second)
+		deadline := timeout * time.Second
+		if routeTimeout, ok := routeTimeouts[c.Request.Method+" "+c.FullPath()]; ok {
+			deadline = routeTimeout
+		}
+
+		// the server cancels the request context when the client goes away, the deadline comes on top
+		var baseCtx context.Context
+		var cancel context.CancelFunc
+		if deadline > 0 {
+			baseCtx, cancel = context.WithTimeout(c.Request.Context(), deadline)
+		} else {
+			baseCtx, cancel = context.WithCancel(c.Request.Context())
+		}
 		defer cancel()
 
-		ctx := context.WithValue(timeoutCtx, "request_id", requestID)
+		ctx := context.WithValue(baseCtx, "request_id", requestID)
 		c.Request = c.Request.WithContext(ctx)
+		c.Set("request_id", requestID)
+		c.Header("X-Request-ID", requestID)
 
 		startTime := time.Now()
 		c.Next()
@@ -0,0 +1,15 @@
+package models
+
+import "errors"
+
+var (
+	ErrInsufficientStock    = errors.New("insufficient stock")
+	ErrReservationNotFound  = errors.New("reservation not found")
+	ErrReservationNotActive = errors.New("reservation is no longer active")
+	ErrVersionConflict      = errors.New("record has been modified by another request")
+	ErrInvalidSort          = errors.New("invalid sort")
+	ErrCategoryNotEmpty     = errors.New("product category still has products")
+	ErrCategoryDeleted      = errors.New("product category is deleted")
+	ErrCategoryCycle        = errors.New("product category cannot be moved below itself")
+	ErrScheduleOverlap      = errors.New("price schedule overlaps another one")
+)
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	HistoryActionCreate  = "create"
+	HistoryActionUpdate  = "update"
+	HistoryActionDelete  = "delete"
+	HistoryActionRestore = "restore"
+)
+
+// AnonymousActor is recorded for changes made without an actor on the context
+const AnonymousActor = "anonymous"
+
+// ProductHistory is one change to a product. Before and After are the product with its variants, price list
+// and sales as read in the changing transaction, Before is nil on create. Changes lists the json fields that differ
+type ProductHistory struct {
+	ID        int64     `json:"id"`
+	ProductID int64     `json:"product_id"`
+	Action    string    `json:"action"`
+	Changes   []string  `json:"changes" gorm:"serializer:json"`
+	Actor     string    `json:"actor"`
+	RequestID string    `json:"request_id,omitempty"`
+	Before    *Product  `json:"before" gorm:"serializer:json"`
+	After     *Product  `json:"after" gorm:"serializer:json"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type ProductHistoryResponse struct {
+	History    []ProductHistory `json:"history"`
+	Page       int              `json:"page"`
+	PageSize   int              `json:"page_size"`
+	TotalCount int              `json:"total_count"`
+	TotalPages int              `json:"total_pages"`
+}
+
+type actorKey struct{}
+
+// ContextWithActor names who makes the changes done with ctx, the repository records it in the product history
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+
+	return AnonymousActor
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+const (
+	MovementReasonRestock   = "restock"
+	MovementReasonSale      = "sale"
+	MovementReasonReturn    = "return"
+	MovementReasonShrinkage = "shrinkage"
+
+	// written by the stock reservation flow, not accepted from clients
+	MovementReasonReserved = "reserved"
+	MovementReasonReleased = "released"
+	MovementReasonExpired  = "expired"
+
+	// written when a bulk import overwrites the stock of an existing product
+	MovementReasonImport = "import"
+
+	// written when a product edit or patch sets a new stock
+	MovementReasonEdit = "edit"
+)
+
+type InventoryMovement struct {
+	ID          int64     `json:"id"`
+	ProductID   int64     `json:"product_id"`
+	Delta       int       `json:"delta"`
+	StockAfter  int       `json:"stock_after"`
+	Reason      string    `json:"reason"`
+	ReferenceID string    `json:"reference_id,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type StockAdjustmentParameter struct {
+	Delta  int    `json:"delta" binding:"required"`
+	Reason string `json:"reason" binding:"required,oneof=restock sale return shrinkage"`
+	Note   string `json:"note"`
+}
+
+type InventoryMovementResponse struct {
+	Movements  []InventoryMovement `json:"movements"`
+	Page       int                 `json:"page"`
+	PageSize   int                 `json:"page_size"`
+	TotalCount int                 `json:"total_count"`
+	TotalPages int                 `json:"total_pages"`
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency prices products created without a currency, existing rows were migrated to it
+const DefaultCurrency = "USD"
+
+// ISO 4217 minor unit exponents that differ from the usual two decimal places
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// CurrencyExponent is the number of decimal places of the currency's minor unit, 2 for USD cents
+func CurrencyExponent(currency string) int {
+	if exponent, ok := currencyExponents[currency]; ok {
+		return exponent
+	}
+
+	return 2
+}
+
+// decimalPlaces is the precision a Decimal holds, enough for every ISO 4217 minor unit
+const (
+	decimalPlaces = 4
+	decimalFactor = 10000
+)
+
+var ErrInvalidDecimal = errors.New("invalid decimal")
+
+// Decimal is an exact amount counted in ten-thousandths, prices never go through float arithmetic.
+// it is read from and written to json as a plain number so existing clients keep working,
+// a quoted number is accepted as well
+type Decimal int64
+
+func ParseDecimal(value string) (Decimal, error) {
+	rat, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return 0, fmt.Errorf("%w: %q is not a number", ErrInvalidDecimal, value)
+	}
+
+	rat.Mul(rat, big.NewRat(decimalFactor, 1))
+	if !rat.IsInt() {
+		return 0, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidDecimal, value, decimalPlaces)
+	}
+
+	if !rat.Num().IsInt64() {
+		return 0, fmt.Errorf("%w: %q is out of range", ErrInvalidDecimal, value)
+	}
+
+	return Decimal(rat.Num().Int64()), nil
+}
+
+// DecimalFromFloat rounds to the nearest ten-thousandth, only meant for configured values
+func DecimalFromFloat(value float64) Decimal {
+	return Decimal(math.Round(value * decimalFactor))
+}
+
+// Places is the number of decimal places needed to write d without loss
+func (d Decimal) Places() int {
+	places := decimalPlaces
+	for remainder := int64(d) % decimalFactor; places > 0 && remainder%10 == 0; remainder /= 10 {
+		places--
+	}
+
+	return places
+}
+
+func (d Decimal) String() string {
+	units := int64(d)
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+
+	whole := strconv.FormatInt(units/decimalFactor, 10)
+
+	places := d.Places()
+	if places == 0 {
+		return sign + whole
+	}
+
+	fraction := fmt.Sprintf("%0*d", decimalPlaces, units%decimalFactor)
+
+	return sign + whole + "." + fraction[:places]
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	raw := string(data)
+	if raw == "null" {
+		return nil
+	}
+
+	if unquoted, err := strconv.Unquote(raw); err == nil {
+		raw = unquoted
+	}
+
+	value, err := ParseDecimal(raw)
+	if err != nil {
+		return err
+	}
+
+	*d = value
+
+	return nil
+}
+
+// Scan reads a numeric column, the driver hands those over as text
+func (d *Decimal) Scan(src interface{}) error {
+	var err error
+
+	switch value := src.(type) {
+	case nil:
+		*d = 0
+	case string:
+		*d, err = ParseDecimal(value)
+	case []byte:
+		*d, err = ParseDecimal(string(value))
+	case int64:
+		*d = Decimal(value * decimalFactor)
+	case float64:
+		*d = DecimalFromFloat(value)
+	default:
+		err = fmt.Errorf("%w: cannot scan %T", ErrInvalidDecimal, src)
+	}
+
+	return err
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// ProductPrice is a price list entry, the price of a product in a currency other than its own
+type ProductPrice struct {
+	ProductID int64   `json:"-"`
+	Currency  string  `json:"currency" binding:"required,iso4217"`
+	Price     Decimal `json:"price" binding:"gte=0"`
+}
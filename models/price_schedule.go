@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// PriceSchedule is a time-boxed sale, the product sells at SalePrice from StartsAt until just before EndsAt.
+// the sale price is in the product's own currency
+type PriceSchedule struct {
+	ID        int64     `json:"id"`
+	ProductID int64     `json:"product_id"`
+	SalePrice Decimal   `json:"sale_price" binding:"gte=0"`
+	StartsAt  time.Time `json:"starts_at" binding:"required"`
+	EndsAt    time.Time `json:"ends_at" binding:"required,gtfield=StartsAt"`
+}
+
+func (p PriceSchedule) ActiveAt(t time.Time) bool {
+	return !t.Before(p.StartsAt) && t.Before(p.EndsAt)
+}
@@ -1,12 +1,73 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// CategoryDeleteModeReassign moves the products of a deleted category to TargetCategoryID
+	CategoryDeleteModeReassign = "reassign"
+	// CategoryDeleteModeCascade soft deletes the products together with their category
+	CategoryDeleteModeCascade = "cascade"
+)
+
 type Product struct {
 	ID          int64   `json:"id"`
-	Name        string  `json:"name"`
+	Name        string  `json:"name" binding:"required,max=255"`
 	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Stock       int     `json:"stock"`
-	CategoryID  int     `json:"category_id"`
+	Price       Decimal `json:"price" binding:"gte=0"`
+	Currency    string  `json:"currency" binding:"omitempty,iso4217"`
+	Stock       int     `json:"stock" binding:"gte=0"`
+	CategoryID  int     `json:"category_id" binding:"required"`
+	Version     int64   `json:"version"`
+
+	// Price is what the product sells for at request time, ListPrice is the price without a running sale.
+	// all three are resolved when the product is read and never written
+	ListPrice  Decimal    `json:"list_price" gorm:"->"`
+	SalePrice  *Decimal   `json:"sale_price" gorm:"->"`
+	SaleEndsAt *time.Time `json:"sale_ends_at" gorm:"->"`
+
+	// set on soft deleted rows, gorm leaves them out of every model query unless Unscoped
+	DeletedAt gorm.DeletedAt `json:"deleted_at"`
+
+	// filled by GetProductByID, root category first
+	Breadcrumbs []CategoryBreadcrumb `json:"breadcrumbs,omitempty" gorm:"-"`
+
+	// filled by the lookups by id, not by search
+	Variants []ProductVariant `json:"variants,omitempty" gorm:"-"`
+	Prices   []ProductPrice   `json:"prices,omitempty" gorm:"-"`
+
+	// sales that have not ended yet, kept with the cached product and left out of responses
+	PriceSchedules []PriceSchedule `json:"price_schedules,omitempty" gorm:"-"`
+
+	// only filled by search
+	Category  string  `json:"category,omitempty" gorm:"->"`
+	Relevance float64 `json:"relevance,omitempty" gorm:"->"`
+	Snippet   string  `json:"snippet,omitempty" gorm:"->"`
+}
+
+// ProductPatch only carries the fields present in the request, nil means leave the column as is
+type ProductPatch struct {
+	Name        *string  `json:"name" binding:"omitempty,min=1,max=255"`
+	Description *string  `json:"description"`
+	Price       *Decimal `json:"price" binding:"omitempty,gte=0"`
+	Currency    *string  `json:"currency" binding:"omitempty,iso4217"`
+	Stock       *int     `json:"stock" binding:"omitempty,gte=0"`
+	CategoryID  *int     `json:"category_id"`
+	Version     int64    `json:"version"`
+}
+
+type BatchGetProductParameter struct {
+	IDs []int64 `json:"ids" binding:"required,min=1,max=100,dive,gt=0"`
+}
+
+// BatchGetProductResult is one requested id, Product is nil when Found is false
+type BatchGetProductResult struct {
+	ID      int64    `json:"id"`
+	Found   bool     `json:"found"`
+	Product *Product `json:"product,omitempty"`
 }
 
 type ProductManagementParameter struct {
@@ -15,31 +76,142 @@ type ProductManagementParameter struct {
 }
 
 type ProductCategory struct {
+	ID        int64          `json:"id"`
+	Name      string         `json:"name" binding:"required,max=255"`
+	ParentID  *int64         `json:"parent_id"`
+	Version   int64          `json:"version"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at"`
+
+	// ancestor ids down to the category itself such as /1/4/9/, maintained by the repository only
+	Path string `json:"-"`
+}
+
+type CategoryBreadcrumb struct {
 	ID   int64  `json:"id"`
 	Name string `json:"name"`
 }
 
+// CategoryNode is one category of a tree response with its live children
+type CategoryNode struct {
+	ID       int64           `json:"id"`
+	Name     string          `json:"name"`
+	ParentID *int64          `json:"parent_id"`
+	Children []*CategoryNode `json:"children"`
+}
+
 type ProductCategoryManagementParameter struct {
 	Action string `json:"action"`
 	ProductCategory
+
+	// only read by the delete action
+	DeleteProductCategoryParameter
+}
+
+// DeleteProductCategoryParameter picks what happens to the products of a non empty category,
+// an empty Mode refuses to delete a category that still has products
+type DeleteProductCategoryParameter struct {
+	Mode             string `json:"mode"`
+	TargetCategoryID int64  `json:"target_category_id"`
 }
 
 type SearchProductParameter struct {
-	Name     string  `json:"name"`
-	Category string  `json:"category"`
-	MinPrice float64 `json:"min_price"`
-	MaxPrice float64 `json:"max_price"`
-	Page     int     `json:"page"`
-	PageSize int     `json:"page_size"`
-	OrderBy  string  `json:"order_by"`
-	Sort     string  `json:"sort"`
+	Name string `json:"name"`
+
+	// filters, nil and empty values leave the result unfiltered; Category matches a name or an ID
+	Category    string   `json:"category"`
+	CategoryIDs []int64  `json:"category_id"`
+	MinPrice    *Decimal `json:"min_price"`
+	MaxPrice    *Decimal `json:"max_price"`
+	InStock     *bool    `json:"in_stock"`
+	IDs         []int64  `json:"ids"`
+	ExcludeIDs  []int64  `json:"exclude_ids"`
+
+	// admin only, soft deleted products are left out otherwise
+	IncludeDeleted bool `json:"include_deleted"`
+
+	// prices, filters, price sort and facets in this currency, products without a price in it are left out.
+	// empty keeps every product in its own currency
+	Currency string `json:"currency"`
+
+	// the moment sale prices are resolved for, set by the service
+	PricedAt time.Time `json:"-"`
+
+	// leaves sales out so price and the price filters are list prices, set by the service for the export
+	ListPrices bool `json:"-"`
+
+	Page         int    `json:"page"`
+	PageSize     int    `json:"page_size"`
+	OrderBy      string `json:"order_by"`
+	Sort         string `json:"sort"`
+	Cursor       string `json:"cursor"`
+	IncludeTotal bool   `json:"include_total"`
+
+	// facets to aggregate over the filtered set, price buckets are ascending lower bounds
+	Facets       []string  `json:"facets"`
+	PriceBuckets []Decimal `json:"price_buckets"`
+
+	// decoded from Cursor by the service, switches the repository to keyset pagination
+	Keyset *SearchCursor `json:"-"`
+}
+
+// SearchCursor holds the sortable values of the row a keyset page starts after, or before when Backward
+type SearchCursor struct {
+	ID        int64   `json:"id"`
+	Name      string  `json:"name"`
+	Price     Decimal `json:"price"`
+	Stock     int     `json:"stock"`
+	Category  string  `json:"category"`
+	Relevance float64 `json:"relevance"`
+	Sort      string  `json:"sort"`
+	Backward  bool    `json:"backward"`
 }
 
 type SearchProductResponse struct {
-	Products    []Product `json:"products"`
-	Page        int       `json:"page"`
-	PageSize    int       `json:"page_size"`
-	TotalCount  int       `json:"total_count"`
-	TotalPages  int       `json:"total_pages"`
-	NextPageUrl *string   `json:"next_page_url"`
+	Products    []Product     `json:"products"`
+	Page        int           `json:"page"`
+	PageSize    int           `json:"page_size"`
+	TotalCount  *int          `json:"total_count,omitempty"`
+	TotalPages  *int          `json:"total_pages,omitempty"`
+	NextPageUrl *string       `json:"next_page_url"`
+	NextCursor  *string       `json:"next_cursor"`
+	PrevCursor  *string       `json:"prev_cursor"`
+	Facets      *SearchFacets `json:"facets,omitempty"`
+}
+
+const (
+	FacetCategory = "category"
+	FacetPrice    = "price"
+	FacetStock    = "stock"
+)
+
+type SearchFacets struct {
+	Category []CategoryFacet `json:"category,omitempty"`
+	Price    []PriceFacet    `json:"price,omitempty"`
+	Stock    *StockFacet     `json:"stock,omitempty"`
+}
+
+type CategoryFacet struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// PriceFacet covers min <= price < max, a nil bound is open
+type PriceFacet struct {
+	Min   *Decimal `json:"min"`
+	Max   *Decimal `json:"max"`
+	Count int      `json:"count"`
+}
+
+type StockFacet struct {
+	InStock    int `json:"in_stock"`
+	OutOfStock int `json:"out_of_stock"`
+}
+
+// FacetRow is one aggregated row of the facet query
+type FacetRow struct {
+	Facet string
+	Key   int64
+	Label string
+	Count int
 }
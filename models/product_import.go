@@ -0,0 +1,44 @@
+package models
+
+const (
+	ImportStatusCreated = "created"
+	ImportStatusUpdated = "updated"
+	ImportStatusFailed  = "failed"
+)
+
+// ProductImportRow is one line of an import file, the category is referenced by name
+type ProductImportRow struct {
+	Line        int     `json:"-"`
+	Name        string  `json:"name" binding:"required,max=255"`
+	Description string  `json:"description"`
+	Price       Decimal `json:"price" binding:"gte=0"`
+	Currency    string  `json:"currency" binding:"omitempty,iso4217"`
+	Stock       int     `json:"stock" binding:"gte=0"`
+	Category    string  `json:"category" binding:"required,max=255"`
+
+	// set when the line could not be parsed or validated, the row is reported as failed
+	Error string `json:"-"`
+}
+
+type ProductImportParameter struct {
+	Rows             []ProductImportRow
+	DryRun           bool
+	CreateCategories bool
+}
+
+type ProductImportResult struct {
+	Line      int    `json:"line"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	ProductID int64  `json:"product_id,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type ProductImportReport struct {
+	DryRun            bool                  `json:"dry_run"`
+	Created           int                   `json:"created"`
+	Updated           int                   `json:"updated"`
+	Failed            int                   `json:"failed"`
+	CategoriesCreated []string              `json:"categories_created"`
+	Rows              []ProductImportResult `json:"rows"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// ProductVariant is one sellable option of a product such as a size and color, a nil Price uses the product price.
+// the price is in the product's currency. Stock is informational and only set through variant writes, reservations,
+// adjustments and the inventory ledger work on the product stock alone
+type ProductVariant struct {
+	ID        int64             `json:"id"`
+	ProductID int64             `json:"product_id"`
+	SKU       string            `json:"sku" binding:"required,max=64"`
+	Options   map[string]string `json:"options" binding:"max=10,dive,keys,min=1,max=50,endkeys,required,max=100" gorm:"serializer:json"`
+	Price     *Decimal          `json:"price" binding:"omitempty,gte=0"`
+	Stock     int               `json:"stock" binding:"gte=0"`
+	Version   int64             `json:"version"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	ReservationStatusReserved  = "reserved"
+	ReservationStatusCommitted = "committed"
+	ReservationStatusReleased  = "released"
+	ReservationStatusExpired   = "expired"
+)
+
+type StockReservation struct {
+	ID        string    `json:"id"`
+	ProductID int64     `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type StockReservationParameter struct {
+	Action        string `json:"action"`
+	ReservationID string `json:"reservation_id" binding:"required_if=Action commit,required_if=Action release"`
+	ProductID     int64  `json:"product_id" binding:"required_if=Action reserve"`
+	Quantity      int    `json:"quantity" binding:"required_if=Action reserve,gte=0"`
+}
@@ -2,18 +2,63 @@ package routes
 
 import (
 	"product/cmd/product/handler"
+	"product/config"
 	"product/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(router *gin.Engine, productHandler handler.ProductHandler) {
-	router.Use(middleware.RequestLogger(5))
-	router.POST("/v1/product", productHandler.ProductManagement)
-	router.POST("/v1/product-category", productHandler.ProductCategoryManagement)
+func SetupRoutes(router *gin.Engine, productHandler handler.ProductHandler, cfg *config.Config) {
+	router.Use(middleware.RequestLogger(5, middleware.RouteTimeouts{
+		"POST /v1/products/import": cfg.Import.Timeout,
+		"GET /v1/products/export":  0,
+	}))
+	router.Use(middleware.AdminToken(cfg.Admin.Token))
+	router.Use(middleware.Actor())
+
+	// action based management, kept until callers move to the resource routes below
+	router.POST("/v1/product", middleware.Deprecated("/v1/products"), productHandler.ProductManagement)
+	router.POST("/v1/product-category", middleware.Deprecated("/v1/product-categories"), productHandler.ProductCategoryManagement)
+	router.POST("/v1/product/reservation", productHandler.StockReservationManagement)
+	router.POST("/v1/product/:id/stock", productHandler.AdjustProductStock)
 
 	router.GET("/v1/product/:id", productHandler.GetProductByID)
 	router.GET("/v1/product-category/:id", productHandler.GetProductCategoryByID)
+	router.GET("/v1/product-category/:id/tree", productHandler.GetProductCategoryTree)
+	router.GET("/v1/product/:id/stock", productHandler.GetInventoryMovements)
+	router.GET("/v1/product/:id/history", productHandler.GetProductHistory)
+
+	router.GET("/v1/product/:id/variants", productHandler.GetProductVariants)
+	router.POST("/v1/product/:id/variants", productHandler.CreateProductVariant)
+	router.GET("/v1/product/:id/variants/:variant_id", productHandler.GetProductVariantByID)
+	router.PUT("/v1/product/:id/variants/:variant_id", productHandler.UpdateProductVariant)
+	router.DELETE("/v1/product/:id/variants/:variant_id", productHandler.DeleteProductVariant)
+
+	router.PUT("/v1/product/:id/prices/:currency", productHandler.SetProductPrice)
+	router.DELETE("/v1/product/:id/prices/:currency", productHandler.DeleteProductPrice)
+
+	router.GET("/v1/product/:id/price-schedules", productHandler.GetPriceSchedules)
+	router.POST("/v1/product/:id/price-schedules", productHandler.CreatePriceSchedule)
+	router.DELETE("/v1/product/:id/price-schedules/:schedule_id", productHandler.DeletePriceSchedule)
 
 	router.GET("v1/product/search", productHandler.SearchProduct)
+
+	// resource routes
+	router.POST("/v1/products", productHandler.CreateProduct)
+	router.POST("/v1/products/batch-get", productHandler.BatchGetProducts)
+	router.POST("/v1/products/import", productHandler.ImportProducts)
+	router.GET("/v1/products/export", productHandler.ExportProducts)
+	router.GET("/v1/products/:id", productHandler.GetProductByID)
+	router.PUT("/v1/products/:id", productHandler.UpdateProduct)
+	router.PATCH("/v1/products/:id", productHandler.PatchProduct)
+	router.DELETE("/v1/products/:id", productHandler.DeleteProduct)
+	router.POST("/v1/products/:id/restore", productHandler.RestoreProduct)
+
+	router.POST("/v1/product-categories", productHandler.CreateProductCategory)
+	router.GET("/v1/product-categories/:id", productHandler.GetProductCategoryByID)
+	router.GET("/v1/product-categories/:id/tree", productHandler.GetProductCategoryTree)
+	router.PUT("/v1/product-categories/:id", productHandler.UpdateProductCategory)
+	router.PATCH("/v1/product-categories/:id", productHandler.PatchProductCategory)
+	router.DELETE("/v1/product-categories/:id", productHandler.DeleteProductCategory)
+	router.POST("/v1/product-categories/:id/restore", productHandler.RestoreProductCategory)
 }